@@ -1,143 +1,297 @@
-package auth
-
-import (
-	"errors"
-	"reflect"
-	"strings"
-	"time"
-
-	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
-)
-
-var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrInvalidUsername  = errors.New("invalid username")
-	ErrInvalidPhone     = errors.New("invalid phone number")
-	ErrWrongCredentials = errors.New("wrong credentials")
-	ErrUsernameExists   = errors.New("username already exists")
-	ErrPhoneExists      = errors.New("phone already exists")
-	ErrInvalidToken     = errors.New("invalid token")
-)
-
-type User struct {
-	ID        int       `json:"id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Username  string    `json:"username" validate:"required"`
-	Phone     string    `json:"phone" validate:"required,e164"`
-	Password  string    `json:"password" validate:"required"`
-	Role      string    `json:"role"`
-	IsAdmin   bool      `json:"is_admin"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at"`
-}
-
-type Users []User
-
-var (
-	Access  = "access"
-	Refresh = "refresh"
-)
-
-type JWTClaim struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Token    string `json:"token"`
-	jwt.RegisteredClaims
-}
-
-func (u *User) SetPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-	u.Password = string(hash)
-	return nil
-}
-
-func (u *User) CheckPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-}
-
-func (u *User) Validate() error {
-	return Validate(u)
-}
-
-func (u *User) ValidatePhone() error {
-	validate := validator.New()
-	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
-		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
-		if name == "-" {
-			return ""
-		}
-		return name
-	})
-	return validate.StructPartial(u, "Phone")
-}
-
-type UseCase interface {
-	Create(user User) (User, error)
-	GetAll() (Users, error)
-	GetByID(id int) (User, error)
-	GetByUsername(username string) (User, error)
-	GetByPhone(phone string) (User, error)
-	Update(id int, user User) (User, error)
-	Delete(id int) error
-	Login(username string, password string) (User, error)
-	GenerateJWT(user User) (map[string]string, error)
-	ValidateJWT(token string) (JWTClaim, error)
-	RefreshJWT(token string) (map[string]string, error)
-	Logout() error
-}
-
-type Repository interface {
-	Create(user User) (User, error)
-	GetAll() (Users, error)
-	GetByID(id int) (User, error)
-	GetByUsername(username string) (User, error)
-	GetByPhone(phone string) (User, error)
-	Update(id int, user User) (User, error)
-	Delete(id int) error
-}
-
-type UserLogin struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
-}
-
-func (u *UserLogin) Validate() error {
-	return Validate(u)
-}
-
-type UserForm struct {
-	FirstName string `json:"first_name" validate:"required"`
-	LastName  string `json:"last_name" validate:"required"`
-	Username  string `json:"username" validate:"required"`
-	Phone     string `json:"phone" validate:"required,e164"`
-	Password  string `json:"password" validate:"required"`
-}
-
-func (u *UserForm) Validate() error {
-	return Validate(u)
-}
-
-func (u *UserForm) ValidatePhone() error {
-	validate := validator.New()
-	RegisterTagNameFunc(validate)
-	return validate.StructPartial(u, "Phone")
-}
-
-func (u *UserForm) ToUserEntity() User {
-	return User{
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Username:  u.Username,
-		Phone:     u.Phone,
-		Password:  u.Password,
-	}
-}
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUserNotFound          = errors.New("user not found")
+	ErrInvalidUsername       = errors.New("invalid username")
+	ErrInvalidPhone          = errors.New("invalid phone number")
+	ErrWrongCredentials      = errors.New("wrong credentials")
+	ErrUsernameExists        = errors.New("username already exists")
+	ErrPhoneExists           = errors.New("phone already exists")
+	ErrEmailExists           = errors.New("email already exists")
+	ErrEmailRequired         = errors.New("email is required")
+	ErrInvalidToken          = errors.New("invalid token")
+	ErrRefreshTokenReused    = errors.New("refresh token already used")
+	ErrSamePassword          = errors.New("new password must be different from the current password")
+	ErrPasswordResetDisabled = errors.New("password reset is not available")
+	ErrUserInactive          = errors.New("user account is disabled")
+)
+
+type User struct {
+	ID            int       `json:"id"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Username      string    `json:"username" validate:"required"`
+	Phone         string    `json:"phone" validate:"required,e164"`
+	Email         string    `json:"email" validate:"omitempty,email"`
+	Password      string    `json:"password" validate:"required"`
+	Role          string    `json:"role"`
+	IsAdmin       bool      `json:"is_admin"`
+	IsActive      bool      `json:"is_active"`
+	PhoneVerified bool      `json:"phone_verified"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	DeletedAt     time.Time `json:"deleted_at"`
+}
+
+type Users []User
+
+// Profile is what a user, or an OAuth client granted the profile scope, sees
+// of the account. It leaves out the password hash and the admin flags.
+type Profile struct {
+	ID            int       `json:"id"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Username      string    `json:"username"`
+	Phone         string    `json:"phone"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	PhoneVerified bool      `json:"phone_verified"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (u User) Profile() Profile {
+	return Profile{
+		ID:            u.ID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Username:      u.Username,
+		Phone:         u.Phone,
+		Email:         u.Email,
+		Role:          u.Role,
+		PhoneVerified: u.PhoneVerified,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+	}
+}
+
+var (
+	Access     = "access"
+	Refresh    = "refresh"
+	MFAPending = "mfa_pending"
+)
+
+type JWTClaim struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	IsAdmin  bool   `json:"is_admin,omitempty"`
+	Token    string `json:"token"`
+	Family   string `json:"fam,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (u *User) SetPassword(password string) error {
+	return u.SetPasswordWith(DefaultPasswordHasher, password)
+}
+
+func (u *User) SetPasswordWith(h PasswordHasher, password string) error {
+	hash, err := h.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	return nil
+}
+
+func (u *User) CheckPassword(password string) error {
+	return VerifyPassword(u.Password, password)
+}
+
+func (u *User) Validate() error {
+	return Validate(u)
+}
+
+func (u *User) ValidatePhone() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate.StructPartial(u, "Phone")
+}
+
+type UseCase interface {
+	Create(user User) (User, error)
+	Signup(user User) (User, error)
+	GetAll() (Users, error)
+	GetByID(id int) (User, error)
+	GetByUsername(username string) (User, error)
+	GetByPhone(phone string) (User, error)
+	GetByEmail(email string) (User, error)
+	Update(id int, user User) (User, error)
+	UpdateSelf(id int, user User, currentPassword string) (User, error)
+	Delete(id int) error
+	DeleteSelf(id int) error
+	Activate(id int) error
+	Deactivate(id int) error
+	Login(username string, password string, clientIP string) (User, error)
+	UnlockLogin(userID int) error
+	GenerateJWT(user User, userAgent string, clientIP string) (map[string]string, error)
+	ValidateJWT(token string, tokenType string) (JWTClaim, error)
+	RefreshJWT(token string, userAgent string, clientIP string) (map[string]string, error)
+	Logout(tokens ...string) error
+	LogoutAll(userID int) error
+	ChangePassword(claim JWTClaim, currentPassword string, newPassword string) error
+	ForgotPassword(identifier string, clientIP string) error
+	ResetPassword(token string, password string) error
+	EnrollTOTP(userID int) (TOTPEnrollment, error)
+	ConfirmTOTP(userID int, code string) ([]string, error)
+	DisableMFA(claim JWTClaim, password string, code string) error
+	MFARequired(user User) (bool, error)
+	GenerateMFAToken(user User) (string, error)
+	VerifyMFA(mfaToken string, code string) (User, error)
+	SendPhoneVerification(phone string) error
+	VerifyPhone(phone string, code string) error
+	SendEmailVerification(userID int) error
+	VerifyEmail(token string) error
+	RequestLoginCode(identifier string, clientIP string) error
+	LoginWithCode(identifier string, code string, clientIP string) (User, error)
+	BeginWebAuthnRegistration(userID int) (CredentialCreationOptions, error)
+	FinishWebAuthnRegistration(userID int, name string, secondFactor bool, c RegistrationCredential) (WebAuthnCredential, error)
+	BeginWebAuthnLogin(clientIP string) (CredentialRequestOptions, error)
+	FinishWebAuthnLogin(c AssertionCredential, clientIP string) (User, error)
+	BeginWebAuthnMFA(mfaToken string) (CredentialRequestOptions, error)
+	FinishWebAuthnMFA(mfaToken string, c AssertionCredential) (User, error)
+	GetWebAuthnCredentials(userID int) ([]WebAuthnCredential, error)
+	DeleteWebAuthnCredential(userID int, id string) error
+	GetSessions(userID int, currentID string) ([]Session, error)
+	RevokeSession(userID int, id string) error
+	AuthenticateIntrospectionClient(clientID string, clientSecret string) error
+	Introspect(token string, tokenTypeHint string) IntrospectionResponse
+	RegisterOAuthClient(form OAuthClientForm) (OAuthClient, error)
+	GetOAuthClients() ([]OAuthClient, error)
+	DeleteOAuthClient(id string) error
+	AuthenticateOAuthClient(clientID string, clientSecret string) (OAuthClient, error)
+	ValidateAuthorizationRequest(r AuthorizationRequest) (OAuthClient, error)
+	Authorize(userID int, r AuthorizationRequest) (string, error)
+	DenyAuthorization(r AuthorizationRequest) (string, error)
+	GrantToken(client OAuthClient, r TokenRequest, userAgent string, clientIP string) (TokenResponse, error)
+}
+
+type Repository interface {
+	Create(user User) (User, error)
+	GetAll() (Users, error)
+	GetByID(id int) (User, error)
+	GetByUsername(username string) (User, error)
+	GetByPhone(phone string) (User, error)
+	// GetByEmail only returns users whose email address is verified, so
+	// unverified addresses can't be used to log in or to claim the address
+	GetByEmail(email string) (User, error)
+	Update(id int, user User) (User, error)
+	SetActive(id int, active bool) error
+	SetPhoneVerified(id int, verified bool) error
+	SetEmailVerified(id int, verified bool) error
+	Delete(id int) error
+}
+
+// RefreshToken is a record of an issued refresh token. Tokens issued by
+// refreshing another token belong to the same family as the original login.
+type RefreshToken struct {
+	ID         string
+	Family     string
+	UserID     int
+	ExpiresAt  time.Time
+	ConsumedAt time.Time
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+}
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token RefreshToken) error
+	// ConsumeRefreshToken atomically marks the token as used. It returns
+	// ErrRefreshTokenReused if the token was already consumed and ErrInvalidToken
+	// if it is unknown, expired or revoked.
+	ConsumeRefreshToken(id string) (RefreshToken, error)
+	RevokeRefreshTokenFamily(family string) error
+	// RevokeUserRefreshTokens revokes every refresh token of the user except
+	// the ones in exceptFamily, which may be empty
+	RevokeUserRefreshTokens(userID int, exceptFamily string) error
+}
+
+// RevocationStore is the list of revoked tokens consulted every time a token
+// is validated. Tokens are identified by their jti claim.
+type RevocationStore interface {
+	RevokeToken(id string, expiresAt time.Time) error
+	IsTokenRevoked(id string) (bool, error)
+	// RevokeUserTokens revokes every token of the user issued before the
+	// given time, or at it since issue times are whole seconds
+	RevokeUserTokens(userID int, issuedBefore time.Time) error
+	UserTokensRevokedAt(userID int) (time.Time, error)
+}
+
+type UserLogin struct {
+	// Username is the username, email address or phone number of the user
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (u *UserLogin) Validate() error {
+	return Validate(u)
+}
+
+type UserForm struct {
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
+	Username  string `json:"username" validate:"required"`
+	Phone     string `json:"phone" validate:"required,e164"`
+	Email     string `json:"email" validate:"omitempty,email"`
+	Password  string `json:"password" validate:"required"`
+
+	// CurrentPassword is required when users change their own email address
+	CurrentPassword string `json:"current_password"`
+}
+
+func (u *UserForm) Validate() error {
+	return Validate(u)
+}
+
+func (u *UserForm) ValidatePhone() error {
+	validate := validator.New()
+	RegisterTagNameFunc(validate)
+	return validate.StructPartial(u, "Phone")
+}
+
+func (u *UserForm) ValidateEmail() error {
+	validate := validator.New()
+	RegisterTagNameFunc(validate)
+	return validate.StructPartial(u, "Email")
+}
+
+type PasswordChangeForm struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+func (f *PasswordChangeForm) Validate() error {
+	return Validate(f)
+}
+
+func (u *UserForm) ToUserEntity() User {
+	return User{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+		Phone:     u.Phone,
+		Email:     u.Email,
+		Password:  u.Password,
+	}
+}
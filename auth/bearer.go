@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>"
+// header value. It returns an empty string for any other scheme.
+func BearerToken(header string) string {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// BasicCredentials extracts the client id and secret from an
+// "Authorization: Basic <credentials>" header value. Both are form url
+// encoded as required by OAuth 2.0 client authentication.
+func BasicCredentials(header string) (string, string, bool) {
+	scheme, credentials, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	id, err = url.QueryUnescape(id)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter")
+
+// bloomFilterMagic starts every file written by BloomFilter.WriteTo
+var bloomFilterMagic = [4]byte{'G', 'A', 'B', 'F'}
+
+const sha1HexLen = sha1.Size * 2
+
+// BreachedPasswordChecker reports whether a password appears in a known
+// breach corpus
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// LoadBreachedPasswordChecker opens either a Bloom filter built by
+// cmd/breachfilter or a Pwned Passwords SHA-1 file ordered by hash, detecting
+// the format from the content of the file
+func LoadBreachedPasswordChecker(path string) (BreachedPasswordChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err == nil && magic == bloomFilterMagic {
+		defer f.Close()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ReadBloomFilter(bufio.NewReader(f))
+	}
+	return NewHashFileChecker(f)
+}
+
+// HashFileChecker looks passwords up in a file of "SHA1:COUNT" lines ordered
+// by hash, as downloaded from Have I Been Pwned, with a binary search on the
+// file so it doesn't have to fit in memory
+type HashFileChecker struct {
+	file *os.File
+	size int64
+}
+
+func NewHashFileChecker(f *os.File) (*HashFileChecker, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &HashFileChecker{file: f, size: info.Size()}, nil
+}
+
+func (c *HashFileChecker) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	low, high := int64(0), c.size
+	for low < high {
+		mid := low + (high-low)/2
+		line, next, err := c.lineAt(mid)
+		if err != nil {
+			return false, err
+		}
+		if len(line) < sha1HexLen {
+			high = mid
+			continue
+		}
+		hash := strings.ToUpper(line[:sha1HexLen])
+		switch {
+		case hash == target:
+			return true, nil
+		case hash < target:
+			low = next
+		default:
+			high = mid
+		}
+	}
+	return false, nil
+}
+
+// lineAt returns the first line starting at or after offset and the offset
+// of the line following it
+func (c *HashFileChecker) lineAt(offset int64) (string, int64, error) {
+	start := offset
+	if start > 0 {
+		start--
+	}
+	buf := make([]byte, 512)
+	n, err := c.file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	buf = buf[:n]
+
+	lineStart := 0
+	if offset > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return "", c.size, nil
+		}
+		lineStart = i + 1
+	}
+	line := buf[lineStart:]
+	next := start + int64(len(buf))
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+		next = start + int64(lineStart+i+1)
+	}
+	return strings.TrimSpace(string(line)), next, nil
+}
+
+func (c *HashFileChecker) Close() error {
+	return c.file.Close()
+}
+
+// BloomFilter is a compact probabilistic set of SHA-1 password hashes. It
+// never misses a breached password but reports a few unbreached ones as
+// breached, at the false positive rate it was built for.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for n hashes with the given false positive
+// rate
+func NewBloomFilter(n uint64, falsePositiveRate float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint32(math.Round(float64(size) / float64(n) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// AddHash adds a SHA-1 hash to the filter
+func (f *BloomFilter) AddHash(sum [sha1.Size]byte) {
+	h1, h2 := splitHash(sum)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *BloomFilter) ContainsHash(sum [sha1.Size]byte) bool {
+	h1, h2 := splitHash(sum)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BloomFilter) IsBreached(password string) (bool, error) {
+	return f.ContainsHash(sha1.Sum([]byte(password))), nil
+}
+
+// WriteTo writes the filter in the format read by ReadBloomFilter
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := struct {
+		Magic  [4]byte
+		Hashes uint32
+		Size   uint64
+	}{bloomFilterMagic, f.hashes, f.size}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, f.bits); err != nil {
+		return 16, err
+	}
+	return 16 + int64(len(f.bits))*8, nil
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	var header struct {
+		Magic  [4]byte
+		Hashes uint32
+		Size   uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != bloomFilterMagic || header.Hashes == 0 || header.Size == 0 {
+		return nil, ErrInvalidBloomFilter
+	}
+	f := &BloomFilter{
+		bits:   make([]uint64, (header.Size+63)/64),
+		size:   header.Size,
+		hashes: header.Hashes,
+	}
+	if err := binary.Read(r, binary.LittleEndian, f.bits); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseSHA1Hash parses the hash at the start of a Pwned Passwords line
+func ParseSHA1Hash(line string) ([sha1.Size]byte, error) {
+	var sum [sha1.Size]byte
+	if len(line) < sha1HexLen {
+		return sum, hex.ErrLength
+	}
+	_, err := hex.Decode(sum[:], []byte(line[:sha1HexLen]))
+	return sum, err
+}
+
+// splitHash derives the two hashes used for double hashing from the SHA-1
+// sum, which is already uniformly distributed
+func splitHash(sum [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[0:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
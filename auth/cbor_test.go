@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"zero", []byte{0x00}, int64(0)},
+		{"small integer", []byte{0x17}, int64(23)},
+		{"one byte integer", []byte{0x18, 0x18}, int64(24)},
+		{"two byte integer", []byte{0x19, 0x01, 0x00}, int64(256)},
+		{"four byte integer", []byte{0x1a, 0x00, 0x01, 0x00, 0x00}, int64(65536)},
+		{"eight byte integer", []byte{0x1b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(1<<63 - 1)},
+		{"negative integer", []byte{0x20}, int64(-1)},
+		{"one byte negative integer", []byte{0x38, 0x63}, int64(-100)},
+		{"two byte negative integer", []byte{0x39, 0x01, 0x00}, int64(-257)},
+		{"byte string", []byte{0x43, 0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03}},
+		{"empty byte string", []byte{0x40}, []byte(nil)},
+		{"text string", []byte{0x63, 'a', 'b', 'c'}, "abc"},
+		{"false", []byte{0xf4}, false},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+		{"undefined", []byte{0xf7}, nil},
+		{"array", []byte{0x82, 0x01, 0x20}, []interface{}{int64(1), int64(-1)}},
+		{"empty array", []byte{0x80}, []interface{}{}},
+		{"map", []byte{0xa2, 0x01, 0x02, 0x61, 'k', 0x40}, map[interface{}]interface{}{int64(1): int64(2), "k": []byte(nil)}},
+		{"nested", []byte{0xa1, 0x20, 0x81, 0xf5}, map[interface{}]interface{}{int64(-1): []interface{}{true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeCBOR(tt.data)
+			if err != nil {
+				t.Fatalf("decodeCBOR(%x): %v", tt.data, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR(%x) = %#v, want %#v", tt.data, got, tt.want)
+			}
+			if len(rest) != 0 {
+				t.Errorf("decodeCBOR(%x) left %x", tt.data, rest)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORRest(t *testing.T) {
+	value, rest, err := decodeCBOR([]byte{0x01, 0x02, 0x03})
+	if err != nil || value != int64(1) || !bytes.Equal(rest, []byte{0x02, 0x03}) {
+		t.Errorf("decodeCBOR = %v, %x, %v, want 1, 0203, nil", value, rest, err)
+	}
+}
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"truncated one byte argument", []byte{0x18}},
+		{"truncated two byte argument", []byte{0x19, 0x01}},
+		{"truncated four byte argument", []byte{0x1a, 0x00, 0x01, 0x00}},
+		{"truncated eight byte argument", []byte{0x1b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"truncated byte string", []byte{0x43, 0x01, 0x02}},
+		{"truncated text string", []byte{0x62, 'a'}},
+		{"truncated array", []byte{0x82, 0x01}},
+		{"truncated map key", []byte{0xa2, 0x01, 0x02}},
+		{"truncated map value", []byte{0xa1, 0x01}},
+		{"truncated nested item", []byte{0x81, 0x42, 0x01}},
+		{"reserved additional information", []byte{0x1c}},
+		{"indefinite byte string", []byte{0x5f, 0x41, 0x01, 0xff}},
+		{"indefinite array", []byte{0x9f, 0x01, 0xff}},
+		{"indefinite map", []byte{0xbf, 0x01, 0x02, 0xff}},
+		{"tag", []byte{0xc0, 0x01}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+		{"one byte simple value", []byte{0xf8, 0x20}},
+		{"break", []byte{0xff}},
+		{"array map key", []byte{0xa1, 0x80, 0x01}},
+		{"byte string map key", []byte{0xa1, 0x40, 0x01}},
+		{"integer above int64", []byte{0x1b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"negative integer below int64", []byte{0x3b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"oversized byte string", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"oversized text string", []byte{0x7a, 0xff, 0xff, 0xff, 0xff, 'a'}},
+		{"oversized array", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"oversized map", []byte{0xba, 0x7f, 0xff, 0xff, 0xff, 0x01, 0x02}},
+		{"too deep", nestedCBORArrays(cborMaxDepth + 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, _, err := decodeCBOR(tt.data); err != ErrInvalidCBOR {
+				t.Errorf("decodeCBOR(%x) = %#v, %v, want ErrInvalidCBOR", tt.data, value, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMaxDepth(t *testing.T) {
+	if _, _, err := decodeCBOR(nestedCBORArrays(cborMaxDepth)); err != nil {
+		t.Errorf("decodeCBOR at the maximum depth: %v", err)
+	}
+}
+
+func TestDecodeCBORMap(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"map", []byte{0xa1, 0x01, 0x02}, true},
+		{"empty map", []byte{0xa0}, true},
+		{"trailing bytes", []byte{0xa1, 0x01, 0x02, 0x00}, false},
+		{"array", []byte{0x81, 0x01}, false},
+		{"integer", []byte{0x01}, false},
+		{"truncated", []byte{0xa1, 0x01}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCBORMap(tt.data)
+			if (err == nil) != tt.ok {
+				t.Errorf("decodeCBORMap(%x) error = %v, want ok %v", tt.data, err, tt.ok)
+			}
+		})
+	}
+}
+
+// nestedCBORArrays returns an empty array nested in arrays of one item, the
+// innermost array being at the given depth
+func nestedCBORArrays(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x81}, depth), 0x80)
+}
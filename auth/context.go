@@ -0,0 +1,51 @@
+package auth
+
+import "context"
+
+type principalKey struct{}
+
+// PrincipalKey is the key the http middlewares store the Principal under in
+// framework specific contexts (gin.Context, fiber.Ctx locals)
+const PrincipalKey = "principal"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Claims JWTClaim
+	// User is only set when the middleware is configured to load it
+	User *User
+}
+
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Principal validates the access token and builds the principal for it,
+// loading the user when Config.LoadPrincipalUser is set. The account status
+// is checked when the user is loaded or Config.CheckUserStatus is set.
+func (s *UserService) Principal(token string) (Principal, error) {
+	claim, err := s.ValidateJWT(token, Access)
+	if err != nil {
+		return Principal{}, err
+	}
+	principal := Principal{Claims: claim}
+	if s.Config.LoadPrincipalUser {
+		user, err := s.repo.GetByID(claim.ID)
+		if err != nil {
+			return Principal{}, ErrInvalidToken
+		}
+		if !user.IsActive {
+			return Principal{}, ErrUserInactive
+		}
+		principal.User = &user
+	} else if s.Config.CheckUserStatus {
+		if err := s.checkActive(claim.ID); err != nil {
+			return Principal{}, err
+		}
+	}
+	return principal, nil
+}
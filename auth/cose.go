@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported public key")
+
+// COSE algorithm identifiers of the keys accepted for WebAuthn credentials
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// COSE key parameters, RFC 9053
+const (
+	coseKeyType  = 1
+	coseKeyAlg   = 3
+	coseKeyCurve = -1
+	coseKeyX     = -2
+	coseKeyY     = -3
+	coseKeyN     = -1
+	coseKeyE     = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// COSEKey is a credential public key in COSE format
+type COSEKey struct {
+	Alg       int64
+	PublicKey crypto.PublicKey
+}
+
+// ParseCOSEKey parses a COSE encoded ES256, EdDSA or RS256 public key
+func ParseCOSEKey(data []byte) (COSEKey, error) {
+	m, err := decodeCBORMap(data)
+	if err != nil {
+		return COSEKey{}, err
+	}
+	return coseKeyFromMap(m)
+}
+
+func coseKeyFromMap(m map[interface{}]interface{}) (COSEKey, error) {
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseKeyAlg)].(int64)
+	switch {
+	case kty == coseKeyTypeEC2 && alg == COSEAlgES256:
+		crv, _ := m[int64(coseKeyCurve)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		y, _ := m[int64(coseKeyY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return COSEKey{}, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return COSEKey{}, ErrUnsupportedKey
+		}
+		return COSEKey{Alg: alg, PublicKey: key}, nil
+	case kty == coseKeyTypeOKP && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(coseKeyCurve)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return COSEKey{}, ErrUnsupportedKey
+		}
+		return COSEKey{Alg: alg, PublicKey: ed25519.PublicKey(x)}, nil
+	case kty == coseKeyTypeRSA && alg == COSEAlgRS256:
+		n, _ := m[int64(coseKeyN)].([]byte)
+		e, _ := m[int64(coseKeyE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return COSEKey{}, ErrUnsupportedKey
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return COSEKey{Alg: alg, PublicKey: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}}, nil
+	}
+	return COSEKey{}, ErrUnsupportedKey
+}
+
+// Verify checks a WebAuthn signature over data made with the key
+func (k COSEKey) Verify(data []byte, signature []byte) bool {
+	return verifyCOSESignature(k.Alg, k.PublicKey, data, signature)
+}
+
+// verifyCOSESignature checks a signature made with the COSE algorithm alg,
+// where ECDSA signatures are ASN.1 encoded as in WebAuthn
+func verifyCOSESignature(alg int64, publicKey crypto.PublicKey, data []byte, signature []byte) bool {
+	switch alg {
+	case COSEAlgES256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, sum[:], signature)
+	case COSEAlgEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(key, data, signature)
+	case COSEAlgRS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+func TestParseCOSEKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("signed data")
+	sum := sha256.Sum256(data)
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSignature := ed25519.Sign(edKey, data)
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ec2 := func(crv int64, x, y []byte) map[int64]interface{} {
+		return map[int64]interface{}{
+			coseKeyType: int64(coseKeyTypeEC2), coseKeyAlg: int64(COSEAlgES256),
+			coseKeyCurve: crv, coseKeyX: x, coseKeyY: y,
+		}
+	}
+	okp := func(crv int64, x []byte) map[int64]interface{} {
+		return map[int64]interface{}{
+			coseKeyType: int64(coseKeyTypeOKP), coseKeyAlg: int64(COSEAlgEdDSA),
+			coseKeyCurve: crv, coseKeyX: x,
+		}
+	}
+	rsaMap := func(n, e []byte) map[int64]interface{} {
+		return map[int64]interface{}{
+			coseKeyType: int64(coseKeyTypeRSA), coseKeyAlg: int64(COSEAlgRS256),
+			coseKeyN: n, coseKeyE: e,
+		}
+	}
+	ecX, ecY := ecKey.X.FillBytes(make([]byte, 32)), ecKey.Y.FillBytes(make([]byte, 32))
+	offCurveY := new(big.Int).Add(ecKey.Y, big.NewInt(1)).FillBytes(make([]byte, 32))
+	rsaE := big.NewInt(int64(rsaKey.E)).Bytes()
+	es256WithEdDSAAlg := ec2(coseCurveP256, ecX, ecY)
+	es256WithEdDSAAlg[coseKeyAlg] = int64(COSEAlgEdDSA)
+
+	tests := []struct {
+		name      string
+		key       map[int64]interface{}
+		alg       int64
+		signature []byte
+		err       error
+	}{
+		{"ES256", ec2(coseCurveP256, ecX, ecY), COSEAlgES256, ecSignature, nil},
+		{"ES256 on P-384", ec2(2, p384Key.X.Bytes(), p384Key.Y.Bytes()), 0, nil, ErrUnsupportedKey},
+		{"ES256 short coordinate", ec2(coseCurveP256, ecX[1:], ecY), 0, nil, ErrUnsupportedKey},
+		{"ES256 missing coordinate", ec2(coseCurveP256, ecX, nil), 0, nil, ErrUnsupportedKey},
+		{"ES256 point off the curve", ec2(coseCurveP256, ecX, offCurveY), 0, nil, ErrUnsupportedKey},
+		{"EC2 key with EdDSA algorithm", es256WithEdDSAAlg, 0, nil, ErrUnsupportedKey},
+		{"EdDSA", okp(coseCurveEd25519, edPublic), COSEAlgEdDSA, edSignature, nil},
+		{"EdDSA on X25519", okp(4, edPublic), 0, nil, ErrUnsupportedKey},
+		{"EdDSA short key", okp(coseCurveEd25519, edPublic[1:]), 0, nil, ErrUnsupportedKey},
+		{"RS256", rsaMap(rsaKey.N.Bytes(), rsaE), COSEAlgRS256, rsaSignature, nil},
+		{"RS256 1024 bit modulus", rsaMap(smallRSAKey.N.Bytes(), rsaE), 0, nil, ErrUnsupportedKey},
+		{"RS256 missing exponent", rsaMap(rsaKey.N.Bytes(), nil), 0, nil, ErrUnsupportedKey},
+		{"RS256 oversized exponent", rsaMap(rsaKey.N.Bytes(), make([]byte, 5)), 0, nil, ErrUnsupportedKey},
+		{"unsupported algorithm", map[int64]interface{}{coseKeyType: int64(coseKeyTypeEC2), coseKeyAlg: int64(-35)}, 0, nil, ErrUnsupportedKey},
+		{"no key type", map[int64]interface{}{}, 0, nil, ErrUnsupportedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseCOSEKey(encodeCOSEKey(tt.key))
+			if err != tt.err {
+				t.Fatalf("ParseCOSEKey error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if key.Alg != tt.alg {
+				t.Errorf("ParseCOSEKey alg = %d, want %d", key.Alg, tt.alg)
+			}
+			if !key.Verify(data, tt.signature) {
+				t.Error("Verify rejected a valid signature")
+			}
+			if key.Verify([]byte("other data"), tt.signature) {
+				t.Error("Verify accepted the signature of other data")
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyInvalidCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a map", []byte{0x81, 0x01}},
+		{"truncated", []byte{0xa2, 0x01, 0x02}},
+		{"trailing bytes", []byte{0xa1, 0x01, 0x02, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCOSEKey(tt.data); err != ErrInvalidCBOR {
+				t.Errorf("ParseCOSEKey(%x) error = %v, want ErrInvalidCBOR", tt.data, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCOSESignatureKeyMismatch(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("signed data")
+	signature := ed25519.Sign(edKey, data)
+	public := edKey.Public()
+	for _, alg := range []int64{COSEAlgES256, COSEAlgRS256, -35} {
+		if verifyCOSESignature(alg, public, data, signature) {
+			t.Errorf("verifyCOSESignature accepted an Ed25519 key as algorithm %d", alg)
+		}
+	}
+}
+
+// encodeCOSEKey encodes a COSE key as a CBOR map. Values are int64 or []byte,
+// nil values are left out.
+func encodeCOSEKey(m map[int64]interface{}) []byte {
+	labels := []int64{}
+	for label, value := range m {
+		if b, ok := value.([]byte); ok && b == nil {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
+
+	data := cborHead(5, uint64(len(labels)))
+	for _, label := range labels {
+		data = append(data, cborInt(label)...)
+		switch value := m[label].(type) {
+		case int64:
+			data = append(data, cborInt(value)...)
+		case []byte:
+			data = append(append(data, cborHead(2, uint64(len(value)))...), value...)
+		}
+	}
+	return data
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+}
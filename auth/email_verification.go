@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmailVerificationDisabled = errors.New("email verification is not available")
+	ErrNoEmail                   = errors.New("user has no email address")
+	ErrEmailAlreadyVerified      = errors.New("email is already verified")
+)
+
+const (
+	NotificationEmailVerification = "email_verification"
+	NotificationEmailChanged      = "email_changed"
+)
+
+// EmailVerification is a pending verification of the email address of a
+// user. Only the hash of the token sent to the address is stored.
+type EmailVerification struct {
+	TokenHash string
+	UserID    int
+	// Email is the address the token was sent to, the token doesn't verify
+	// any other address the user changes to
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+type EmailVerificationRepository interface {
+	CreateEmailVerification(v EmailVerification) error
+	// ConsumeEmailVerification atomically marks the verification as used. It
+	// returns ErrInvalidToken if it is unknown, expired or already used.
+	ConsumeEmailVerification(tokenHash string) (EmailVerification, error)
+	DeleteUserEmailVerifications(userID int) error
+}
+
+type VerifyEmailForm struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (f *VerifyEmailForm) Validate() error {
+	return Validate(f)
+}
+
+// WithEmailVerificationRepository enables email address verification, which
+// also needs a Notifier
+func WithEmailVerificationRepository(r EmailVerificationRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.emailVerifications = r
+	}
+}
+
+// NormalizeEmail trims and lowercases an email address so lookups and
+// uniqueness checks don't depend on how it was typed
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SendEmailVerification sends a single use verification link to the email
+// address of the user, replacing any link sent before
+func (s *UserService) SendEmailVerification(userID int) error {
+	if s.emailVerifications == nil || s.notifier == nil {
+		return ErrEmailVerificationDisabled
+	}
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	if err := s.emailVerifications.DeleteUserEmailVerifications(user.ID); err != nil {
+		return err
+	}
+	err = s.emailVerifications.CreateEmailVerification(EmailVerification{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(time.Duration(s.Config.EmailVerificationExpTime) * time.Minute),
+	})
+	if err != nil {
+		return err
+	}
+
+	notification := Notification{
+		Kind:    NotificationEmailVerification,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Use this token to verify your email address within %d minutes: %s",
+			s.Config.EmailVerificationExpTime, token),
+		Data: map[string]string{"token": token},
+	}
+	if s.Config.EmailVerificationURL != "" {
+		link := s.Config.EmailVerificationURL + token
+		notification.Body = fmt.Sprintf("Open this link to verify your email address within %d minutes: %s",
+			s.Config.EmailVerificationExpTime, link)
+		notification.Data["link"] = link
+	}
+	go func() {
+		if err := s.notifier.Notify(user, notification); err != nil {
+			log.Println("Error sending email verification notification. Error: ", err)
+		}
+	}()
+	return nil
+}
+
+// VerifyEmail marks the email address of the user as verified using a token
+// sent by SendEmailVerification
+func (s *UserService) VerifyEmail(token string) error {
+	if s.emailVerifications == nil {
+		return ErrEmailVerificationDisabled
+	}
+	verification, err := s.emailVerifications.ConsumeEmailVerification(hashToken(token))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	user, err := s.repo.GetByID(verification.UserID)
+	if err != nil || user.Email != verification.Email {
+		return ErrInvalidToken
+	}
+	if owner, err := s.repo.GetByEmail(user.Email); err == nil && owner.ID != user.ID {
+		return ErrEmailExists
+	}
+	if err := s.repo.SetEmailVerified(user.ID, true); err != nil {
+		return err
+	}
+	return s.emailVerifications.DeleteUserEmailVerifications(user.ID)
+}
+
+// sendSignupEmailVerification sends the verification link to a new user.
+// Failures are logged and don't fail the signup.
+func (s *UserService) sendSignupEmailVerification(user User) {
+	if s.emailVerifications == nil || s.notifier == nil || user.Email == "" {
+		return
+	}
+	if err := s.SendEmailVerification(user.ID); err != nil {
+		log.Println("Error sending email verification. Error: ", err)
+	}
+}
+
+// notifyEmailChanged tells the previous address of the user that the email
+// address of the account was changed
+func (s *UserService) notifyEmailChanged(previous User, email string) {
+	if s.notifier == nil {
+		log.Printf("Email address of user %d changed, no notifier configured", previous.ID)
+		return
+	}
+	notification := Notification{
+		Kind:    NotificationEmailChanged,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("The email address of your account was changed to %s. "+
+			"If you didn't make this change, reset your password and contact support.", email),
+		Data: map[string]string{"email": email},
+	}
+	go func() {
+		if err := s.notifier.Notify(previous, notification); err != nil {
+			log.Println("Error sending email change notification. Error: ", err)
+		}
+	}()
+}
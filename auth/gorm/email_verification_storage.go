@@ -0,0 +1,64 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+)
+
+type GormEmailVerification struct {
+	TokenHash string `gorm:"primaryKey"`
+	UserID    int    `gorm:"index"`
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v GormEmailVerification) ToEntity() auth.EmailVerification {
+	verification := auth.EmailVerification{
+		TokenHash: v.TokenHash,
+		UserID:    v.UserID,
+		Email:     v.Email,
+		ExpiresAt: v.ExpiresAt,
+		CreatedAt: v.CreatedAt,
+	}
+	if v.UsedAt != nil {
+		verification.UsedAt = *v.UsedAt
+	}
+	return verification
+}
+
+func (r *GormRepository) CreateEmailVerification(v auth.EmailVerification) error {
+	verification := GormEmailVerification{
+		TokenHash: v.TokenHash,
+		UserID:    v.UserID,
+		Email:     v.Email,
+		ExpiresAt: v.ExpiresAt,
+	}
+	return r.db.Create(&verification).Error
+}
+
+func (r *GormRepository) ConsumeEmailVerification(tokenHash string) (auth.EmailVerification, error) {
+	now := time.Now()
+	result := r.db.Model(&GormEmailVerification{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return auth.EmailVerification{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.EmailVerification{}, auth.ErrInvalidToken
+	}
+
+	var verification GormEmailVerification
+	err := r.db.Where("token_hash = ?", tokenHash).First(&verification).Error
+	if err != nil {
+		return auth.EmailVerification{}, err
+	}
+	return verification.ToEntity(), nil
+}
+
+func (r *GormRepository) DeleteUserEmailVerifications(userID int) error {
+	return r.db.Where("user_id = ?", userID).Delete(&GormEmailVerification{}).Error
+}
@@ -0,0 +1,58 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormLoginAttempt struct {
+	Key         string `gorm:"primaryKey"`
+	Failures    int
+	LastFailure time.Time `gorm:"index"`
+}
+
+func (a GormLoginAttempt) ToEntity() auth.LoginAttempts {
+	return auth.LoginAttempts{
+		Key:         a.Key,
+		Failures:    a.Failures,
+		LastFailure: a.LastFailure,
+	}
+}
+
+func (r *GormRepository) GetLoginAttempts(key string) (auth.LoginAttempts, error) {
+	var attempts []GormLoginAttempt
+	err := r.db.Where("key = ?", key).Limit(1).Find(&attempts).Error
+	if err != nil {
+		return auth.LoginAttempts{}, err
+	}
+	if len(attempts) == 0 {
+		return auth.LoginAttempts{Key: key}, nil
+	}
+	return attempts[0].ToEntity(), nil
+}
+
+func (r *GormRepository) RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (auth.LoginAttempts, error) {
+	err := r.db.Where("last_failure < ?", resetBefore).Delete(&GormLoginAttempt{}).Error
+	if err != nil {
+		return auth.LoginAttempts{}, err
+	}
+	attempt := GormLoginAttempt{Key: key, Failures: 1, LastFailure: at}
+	err = r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":     gorm.Expr("gorm_login_attempts.failures + 1"),
+			"last_failure": at,
+		}),
+	}).Create(&attempt).Error
+	if err != nil {
+		return auth.LoginAttempts{}, err
+	}
+	return r.GetLoginAttempts(key)
+}
+
+func (r *GormRepository) ResetLoginAttempts(key string) error {
+	return r.db.Where("key = ?", key).Delete(&GormLoginAttempt{}).Error
+}
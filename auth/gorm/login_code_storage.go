@@ -0,0 +1,69 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormLoginCode struct {
+	UserID    int `gorm:"primaryKey;autoIncrement:false"`
+	CodeHash  string
+	Attempts  int
+	Sends     int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+func (c GormLoginCode) ToEntity() auth.LoginCode {
+	return auth.LoginCode{
+		UserID:    c.UserID,
+		CodeHash:  c.CodeHash,
+		Attempts:  c.Attempts,
+		Sends:     c.Sends,
+		SentAt:    c.SentAt,
+		ExpiresAt: c.ExpiresAt,
+	}
+}
+
+func (r *GormRepository) GetLoginCode(userID int) (auth.LoginCode, error) {
+	var code GormLoginCode
+	err := r.db.Where("user_id = ?", userID).First(&code).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.LoginCode{}, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return auth.LoginCode{}, err
+	}
+	return code.ToEntity(), nil
+}
+
+func (r *GormRepository) SaveLoginCode(c auth.LoginCode) error {
+	code := GormLoginCode{
+		UserID:    c.UserID,
+		CodeHash:  c.CodeHash,
+		Attempts:  c.Attempts,
+		Sends:     c.Sends,
+		SentAt:    c.SentAt,
+		ExpiresAt: c.ExpiresAt,
+	}
+	return r.db.Save(&code).Error
+}
+
+func (r *GormRepository) RecordLoginCodeAttempt(userID int) error {
+	return r.db.Model(&GormLoginCode{}).Where("user_id = ?", userID).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+func (r *GormRepository) ConsumeLoginCode(userID int, codeHash string, maxAttempts int) error {
+	result := r.db.Where("user_id = ? AND code_hash = ? AND attempts < ? AND expires_at > ?", userID, codeHash, maxAttempts, time.Now()).
+		Delete(&GormLoginCode{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormMFA struct {
+	UserID       int `gorm:"primaryKey;autoIncrement:false"`
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type GormRecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   int    `gorm:"index"`
+	CodeHash string `gorm:"index"`
+	UsedAt   *time.Time
+}
+
+func (m GormMFA) ToEntity() auth.MFA {
+	return auth.MFA{
+		UserID:       m.UserID,
+		Secret:       m.Secret,
+		Enabled:      m.Enabled,
+		LastUsedStep: m.LastUsedStep,
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+func (r *GormRepository) GetMFA(userID int) (auth.MFA, error) {
+	var mfa []GormMFA
+	err := r.db.Where("user_id = ?", userID).Limit(1).Find(&mfa).Error
+	if err != nil {
+		return auth.MFA{}, err
+	}
+	if len(mfa) == 0 {
+		return auth.MFA{UserID: userID}, nil
+	}
+	return mfa[0].ToEntity(), nil
+}
+
+func (r *GormRepository) SaveMFA(m auth.MFA) error {
+	mfa := GormMFA{
+		UserID:       m.UserID,
+		Secret:       m.Secret,
+		Enabled:      m.Enabled,
+		LastUsedStep: m.LastUsedStep,
+		CreatedAt:    m.CreatedAt,
+	}
+	if mfa.CreatedAt.IsZero() {
+		mfa.CreatedAt = time.Now()
+	}
+	return r.db.Save(&mfa).Error
+}
+
+func (r *GormRepository) DeleteMFA(userID int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&GormRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&GormMFA{}).Error
+	})
+}
+
+func (r *GormRepository) UseTOTPStep(userID int, step int64) error {
+	result := r.db.Model(&GormMFA{}).
+		Where("user_id = ? AND enabled AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (r *GormRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&GormRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]GormRecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = GormRecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *GormRepository) UseRecoveryCode(userID int, codeHash string) error {
+	result := r.db.Model(&GormRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrInvalidMFACode
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package gorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormMigration records a data migration that was applied, so it isn't
+// applied again once operators changed the data
+type GormMigration struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// migrateOnce applies the migration unless it was applied before
+func (r *GormRepository) migrateOnce(name string, migrate func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&GormMigration{}).Where("name = ?", name).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		migration := GormMigration{Name: name, AppliedAt: time.Now()}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&migration).Error
+	})
+}
@@ -0,0 +1,134 @@
+package gorm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormOAuthClient struct {
+	ID           string `gorm:"primaryKey"`
+	SecretHash   string
+	Name         string
+	RedirectURIs string // space separated, spaces can't appear in uris
+	Public       bool
+	CreatedAt    time.Time
+}
+
+type GormAuthorizationCode struct {
+	CodeHash      string `gorm:"primaryKey"`
+	ClientID      string
+	UserID        int
+	RedirectURI   string
+	CodeChallenge string
+	Scope         string
+	ExpiresAt     time.Time `gorm:"index"`
+}
+
+func (c GormOAuthClient) ToEntity() auth.OAuthClient {
+	return auth.OAuthClient{
+		ID:           c.ID,
+		SecretHash:   c.SecretHash,
+		Name:         c.Name,
+		RedirectURIs: strings.Fields(c.RedirectURIs),
+		Public:       c.Public,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+func (c GormAuthorizationCode) ToEntity() auth.AuthorizationCode {
+	return auth.AuthorizationCode{
+		CodeHash:      c.CodeHash,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectURI:   c.RedirectURI,
+		CodeChallenge: c.CodeChallenge,
+		Scope:         c.Scope,
+		ExpiresAt:     c.ExpiresAt,
+	}
+}
+
+func (r *GormRepository) CreateOAuthClient(c auth.OAuthClient) error {
+	client := GormOAuthClient{
+		ID:           c.ID,
+		SecretHash:   c.SecretHash,
+		Name:         c.Name,
+		RedirectURIs: strings.Join(c.RedirectURIs, " "),
+		Public:       c.Public,
+	}
+	return r.db.Create(&client).Error
+}
+
+func (r *GormRepository) GetOAuthClient(id string) (auth.OAuthClient, error) {
+	var client GormOAuthClient
+	err := r.db.Where("id = ?", id).First(&client).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.OAuthClient{}, auth.ErrInvalidClient
+	}
+	if err != nil {
+		return auth.OAuthClient{}, err
+	}
+	return client.ToEntity(), nil
+}
+
+func (r *GormRepository) GetOAuthClients() ([]auth.OAuthClient, error) {
+	var clients []GormOAuthClient
+	err := r.db.Order("created_at").Find(&clients).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]auth.OAuthClient, 0, len(clients))
+	for _, c := range clients {
+		result = append(result, c.ToEntity())
+	}
+	return result, nil
+}
+
+func (r *GormRepository) DeleteOAuthClient(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&GormOAuthClient{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrInvalidClient
+	}
+	return r.db.Where("client_id = ?", id).Delete(&GormAuthorizationCode{}).Error
+}
+
+func (r *GormRepository) CreateAuthorizationCode(c auth.AuthorizationCode) error {
+	err := r.db.Where("expires_at < ?", time.Now()).Delete(&GormAuthorizationCode{}).Error
+	if err != nil {
+		return err
+	}
+	code := GormAuthorizationCode{
+		CodeHash:      c.CodeHash,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectURI:   c.RedirectURI,
+		CodeChallenge: c.CodeChallenge,
+		Scope:         c.Scope,
+		ExpiresAt:     c.ExpiresAt,
+	}
+	return r.db.Create(&code).Error
+}
+
+func (r *GormRepository) ConsumeAuthorizationCode(codeHash string) (auth.AuthorizationCode, error) {
+	var code GormAuthorizationCode
+	err := r.db.Where("code_hash = ? AND expires_at > ?", codeHash, time.Now()).First(&code).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.AuthorizationCode{}, auth.ErrInvalidGrant
+	}
+	if err != nil {
+		return auth.AuthorizationCode{}, err
+	}
+	result := r.db.Where("code_hash = ?", codeHash).Delete(&GormAuthorizationCode{})
+	if result.Error != nil {
+		return auth.AuthorizationCode{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.AuthorizationCode{}, auth.ErrInvalidGrant
+	}
+	return code.ToEntity(), nil
+}
@@ -0,0 +1,83 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormOpaqueToken struct {
+	TokenHash string `gorm:"primaryKey"`
+	ID        string
+	UserID    int `gorm:"index"`
+	Username  string
+	Role      string
+	IsAdmin   bool
+	Family    string `gorm:"index"`
+	ClientID  string
+	Scope     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (t GormOpaqueToken) ToEntity() auth.OpaqueToken {
+	return auth.OpaqueToken{
+		TokenHash: t.TokenHash,
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Username:  t.Username,
+		Role:      t.Role,
+		IsAdmin:   t.IsAdmin,
+		Family:    t.Family,
+		ClientID:  t.ClientID,
+		Scope:     t.Scope,
+		IssuedAt:  t.IssuedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+}
+
+func (r *GormRepository) CreateOpaqueToken(t auth.OpaqueToken) error {
+	err := r.db.Where("user_id = ? AND expires_at < ?", t.UserID, time.Now()).Delete(&GormOpaqueToken{}).Error
+	if err != nil {
+		return err
+	}
+	token := GormOpaqueToken{
+		TokenHash: t.TokenHash,
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Username:  t.Username,
+		Role:      t.Role,
+		IsAdmin:   t.IsAdmin,
+		Family:    t.Family,
+		ClientID:  t.ClientID,
+		Scope:     t.Scope,
+		IssuedAt:  t.IssuedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+	return r.db.Create(&token).Error
+}
+
+func (r *GormRepository) GetOpaqueToken(tokenHash string) (auth.OpaqueToken, error) {
+	var token GormOpaqueToken
+	err := r.db.Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&token).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.OpaqueToken{}, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return auth.OpaqueToken{}, err
+	}
+	return token.ToEntity(), nil
+}
+
+func (r *GormRepository) DeleteOpaqueToken(tokenHash string) error {
+	return r.db.Where("token_hash = ?", tokenHash).Delete(&GormOpaqueToken{}).Error
+}
+
+func (r *GormRepository) DeleteOpaqueTokenFamily(family string) error {
+	return r.db.Where("family = ?", family).Delete(&GormOpaqueToken{}).Error
+}
+
+func (r *GormRepository) DeleteUserOpaqueTokens(userID int, exceptFamily string) error {
+	return r.db.Where("user_id = ? AND family <> ?", userID, exceptFamily).Delete(&GormOpaqueToken{}).Error
+}
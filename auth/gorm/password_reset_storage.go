@@ -0,0 +1,90 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormPasswordReset struct {
+	TokenHash string `gorm:"primaryKey"`
+	UserID    int    `gorm:"index"`
+	Sends     int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (p GormPasswordReset) ToEntity() auth.PasswordReset {
+	reset := auth.PasswordReset{
+		TokenHash: p.TokenHash,
+		UserID:    p.UserID,
+		Sends:     p.Sends,
+		ExpiresAt: p.ExpiresAt,
+		CreatedAt: p.CreatedAt,
+	}
+	if p.UsedAt != nil {
+		reset.UsedAt = *p.UsedAt
+	}
+	return reset
+}
+
+func (r *GormRepository) CreatePasswordReset(p auth.PasswordReset) error {
+	reset := GormPasswordReset{
+		TokenHash: p.TokenHash,
+		UserID:    p.UserID,
+		Sends:     p.Sends,
+		ExpiresAt: p.ExpiresAt,
+		CreatedAt: p.CreatedAt,
+	}
+	return r.db.Create(&reset).Error
+}
+
+func (r *GormRepository) GetPasswordReset(tokenHash string) (auth.PasswordReset, error) {
+	var reset GormPasswordReset
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&reset).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.PasswordReset{}, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return auth.PasswordReset{}, err
+	}
+	return reset.ToEntity(), nil
+}
+
+func (r *GormRepository) ConsumePasswordReset(tokenHash string) (auth.PasswordReset, error) {
+	now := time.Now()
+	result := r.db.Model(&GormPasswordReset{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return auth.PasswordReset{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.PasswordReset{}, auth.ErrInvalidToken
+	}
+
+	var reset GormPasswordReset
+	err := r.db.Where("token_hash = ?", tokenHash).First(&reset).Error
+	if err != nil {
+		return auth.PasswordReset{}, err
+	}
+	return reset.ToEntity(), nil
+}
+
+func (r *GormRepository) GetUserPasswordReset(userID int) (auth.PasswordReset, error) {
+	var reset GormPasswordReset
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&reset).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.PasswordReset{}, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return auth.PasswordReset{}, err
+	}
+	return reset.ToEntity(), nil
+}
+
+func (r *GormRepository) DeleteUserPasswordResets(userID int) error {
+	return r.db.Where("user_id = ?", userID).Delete(&GormPasswordReset{}).Error
+}
@@ -0,0 +1,75 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormPhoneVerification struct {
+	UserID    int `gorm:"primaryKey;autoIncrement:false"`
+	Phone     string
+	CodeHash  string
+	Attempts  int
+	Sends     int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+func (v GormPhoneVerification) ToEntity() auth.PhoneVerification {
+	return auth.PhoneVerification{
+		UserID:    v.UserID,
+		Phone:     v.Phone,
+		CodeHash:  v.CodeHash,
+		Attempts:  v.Attempts,
+		Sends:     v.Sends,
+		SentAt:    v.SentAt,
+		ExpiresAt: v.ExpiresAt,
+	}
+}
+
+func (r *GormRepository) GetPhoneVerification(userID int) (auth.PhoneVerification, error) {
+	var verification GormPhoneVerification
+	err := r.db.Where("user_id = ?", userID).First(&verification).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.PhoneVerification{}, auth.ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return auth.PhoneVerification{}, err
+	}
+	return verification.ToEntity(), nil
+}
+
+func (r *GormRepository) SavePhoneVerification(v auth.PhoneVerification) error {
+	verification := GormPhoneVerification{
+		UserID:    v.UserID,
+		Phone:     v.Phone,
+		CodeHash:  v.CodeHash,
+		Attempts:  v.Attempts,
+		Sends:     v.Sends,
+		SentAt:    v.SentAt,
+		ExpiresAt: v.ExpiresAt,
+	}
+	return r.db.Save(&verification).Error
+}
+
+func (r *GormRepository) RecordPhoneVerificationAttempt(userID int) (int, error) {
+	result := r.db.Model(&GormPhoneVerification{}).Where("user_id = ?", userID).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, auth.ErrInvalidVerificationCode
+	}
+	verification, err := r.GetPhoneVerification(userID)
+	if err != nil {
+		return 0, err
+	}
+	return verification.Attempts, nil
+}
+
+func (r *GormRepository) DeletePhoneVerification(userID int) error {
+	return r.db.Where("user_id = ?", userID).Delete(&GormPhoneVerification{}).Error
+}
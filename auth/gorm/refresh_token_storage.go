@@ -0,0 +1,83 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+)
+
+type GormRefreshToken struct {
+	ID         string `gorm:"primaryKey"`
+	Family     string `gorm:"index"`
+	UserID     int    `gorm:"index"`
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (t GormRefreshToken) ToEntity() auth.RefreshToken {
+	token := auth.RefreshToken{
+		ID:        t.ID,
+		Family:    t.Family,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.ConsumedAt != nil {
+		token.ConsumedAt = *t.ConsumedAt
+	}
+	if t.RevokedAt != nil {
+		token.RevokedAt = *t.RevokedAt
+	}
+	return token
+}
+
+func (r *GormRepository) CreateRefreshToken(t auth.RefreshToken) error {
+	err := r.db.Where("user_id = ? AND expires_at < ?", t.UserID, time.Now()).Delete(&GormRefreshToken{}).Error
+	if err != nil {
+		return err
+	}
+	token := GormRefreshToken{
+		ID:        t.ID,
+		Family:    t.Family,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+	}
+	return r.db.Create(&token).Error
+}
+
+func (r *GormRepository) ConsumeRefreshToken(id string) (auth.RefreshToken, error) {
+	now := time.Now()
+	result := r.db.Model(&GormRefreshToken{}).
+		Where("id = ? AND consumed_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		return auth.RefreshToken{}, result.Error
+	}
+
+	var token GormRefreshToken
+	err := r.db.Where("id = ?", id).First(&token).Error
+	if err != nil {
+		return auth.RefreshToken{}, auth.ErrInvalidToken
+	}
+	if result.RowsAffected == 1 {
+		return token.ToEntity(), nil
+	}
+	if token.ConsumedAt != nil {
+		return token.ToEntity(), auth.ErrRefreshTokenReused
+	}
+	return token.ToEntity(), auth.ErrInvalidToken
+}
+
+func (r *GormRepository) RevokeRefreshTokenFamily(family string) error {
+	return r.db.Model(&GormRefreshToken{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *GormRepository) RevokeUserRefreshTokens(userID int, exceptFamily string) error {
+	return r.db.Model(&GormRefreshToken{}).
+		Where("user_id = ? AND family <> ? AND revoked_at IS NULL", userID, exceptFamily).
+		Update("revoked_at", time.Now()).Error
+}
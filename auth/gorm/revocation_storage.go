@@ -0,0 +1,49 @@
+package gorm
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type GormRevokedToken struct {
+	ID        string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+type GormUserRevocation struct {
+	UserID    int `gorm:"primaryKey;autoIncrement:false"`
+	RevokedAt time.Time
+}
+
+func (r *GormRepository) RevokeToken(id string, expiresAt time.Time) error {
+	err := r.db.Where("expires_at < ?", time.Now()).Delete(&GormRevokedToken{}).Error
+	if err != nil {
+		return err
+	}
+	token := GormRevokedToken{ID: id, ExpiresAt: expiresAt}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&token).Error
+}
+
+func (r *GormRepository) IsTokenRevoked(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&GormRevokedToken{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *GormRepository) RevokeUserTokens(userID int, issuedBefore time.Time) error {
+	revocation := GormUserRevocation{UserID: userID, RevokedAt: issuedBefore}
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&revocation).Error
+}
+
+func (r *GormRepository) UserTokensRevokedAt(userID int) (time.Time, error) {
+	var revocations []GormUserRevocation
+	err := r.db.Where("user_id = ?", userID).Limit(1).Find(&revocations).Error
+	if err != nil || len(revocations) == 0 {
+		return time.Time{}, err
+	}
+	return revocations[0].RevokedAt, nil
+}
@@ -0,0 +1,93 @@
+package gorm
+
+import (
+	"strings"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormRole struct {
+	Name        string `gorm:"primaryKey"`
+	Permissions string
+}
+
+func NewFromAuthRole(r auth.Role) GormRole {
+	permissions := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		permissions[i] = string(p)
+	}
+	return GormRole{
+		Name:        r.Name,
+		Permissions: strings.Join(permissions, ","),
+	}
+}
+
+func (r GormRole) ToEntity() auth.Role {
+	role := auth.Role{Name: r.Name, Permissions: []auth.Permission{}}
+	for _, p := range strings.Split(r.Permissions, ",") {
+		if p != "" {
+			role.Permissions = append(role.Permissions, auth.Permission(p))
+		}
+	}
+	return role
+}
+
+// seedRoles creates the default roles that do not exist yet
+func (r *GormRepository) seedRoles() error {
+	for _, role := range auth.DefaultRoles {
+		gormRole := NewFromAuthRole(role)
+		err := r.db.Where(GormRole{Name: role.Name}).FirstOrCreate(&gormRole).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grantClientManagement gives the admin role seeded before OAuth clients
+// existed the permission to manage them
+func grantClientManagement(tx *gorm.DB) error {
+	var role GormRole
+	err := tx.Where("name = ?", auth.RoleAdmin).First(&role).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	admin := role.ToEntity()
+	if admin.Has(auth.PermissionManageClients) {
+		return nil
+	}
+	admin.Permissions = append(admin.Permissions, auth.PermissionManageClients)
+	role = NewFromAuthRole(admin)
+	return tx.Save(&role).Error
+}
+
+func (r *GormRepository) GetRole(name string) (auth.Role, error) {
+	var role GormRole
+	err := r.db.Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return auth.Role{}, err
+	}
+	return role.ToEntity(), nil
+}
+
+func (r *GormRepository) GetRoles() (auth.Roles, error) {
+	var roles []GormRole
+	err := r.db.Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	var rolesEntity auth.Roles
+	for _, role := range roles {
+		rolesEntity = append(rolesEntity, role.ToEntity())
+	}
+	return rolesEntity, nil
+}
+
+func (r *GormRepository) SaveRole(role auth.Role) error {
+	gormRole := NewFromAuthRole(role)
+	return r.db.Save(&gormRole).Error
+}
@@ -0,0 +1,111 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+)
+
+type GormSession struct {
+	ID         string `gorm:"primaryKey"`
+	UserID     int    `gorm:"index"`
+	ClientID   string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (s GormSession) ToEntity() auth.Session {
+	session := auth.Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		ClientID:   s.ClientID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+	if s.RevokedAt != nil {
+		session.RevokedAt = *s.RevokedAt
+	}
+	return session
+}
+
+func (r *GormRepository) CreateSession(s auth.Session) error {
+	err := r.db.Where("user_id = ? AND expires_at < ?", s.UserID, time.Now()).Delete(&GormSession{}).Error
+	if err != nil {
+		return err
+	}
+	session := GormSession{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		ClientID:   s.ClientID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  s.ExpiresAt,
+	}
+	return r.db.Create(&session).Error
+}
+
+func (r *GormRepository) TouchSession(id string, userAgent string, ip string, expiresAt time.Time) error {
+	return r.db.Model(&GormSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"user_agent":   userAgent,
+			"ip":           ip,
+			"last_seen_at": time.Now(),
+			"expires_at":   expiresAt,
+		}).Error
+}
+
+func (r *GormRepository) GetSession(id string) (auth.Session, error) {
+	var session GormSession
+	err := r.db.Where("id = ?", id).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.Session{}, auth.ErrSessionNotFound
+	}
+	if err != nil {
+		return auth.Session{}, err
+	}
+	return session.ToEntity(), nil
+}
+
+func (r *GormRepository) GetUserSessions(userID int) ([]auth.Session, error) {
+	var sessions []GormSession
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]auth.Session, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, s.ToEntity())
+	}
+	return result, nil
+}
+
+func (r *GormRepository) RevokeSession(userID int, id string) error {
+	result := r.db.Model(&GormSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *GormRepository) RevokeUserSessions(userID int, exceptID string) error {
+	return r.db.Model(&GormSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,52 @@
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+)
+
+// GormSigningKey is a rotated signing key. Key holds private key material in
+// the clear, so access to the table must be restricted like the key files.
+type GormSigningKey struct {
+	ID        string `gorm:"primaryKey"`
+	Algorithm string
+	Key       []byte
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (k GormSigningKey) ToEntity() auth.StoredSigningKey {
+	return auth.StoredSigningKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		Key:       k.Key,
+		CreatedAt: k.CreatedAt,
+	}
+}
+
+func (r *GormRepository) CreateSigningKey(k auth.StoredSigningKey) error {
+	key := GormSigningKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		Key:       k.Key,
+		CreatedAt: k.CreatedAt,
+	}
+	return r.db.Create(&key).Error
+}
+
+func (r *GormRepository) GetSigningKeys() ([]auth.StoredSigningKey, error) {
+	var keys []GormSigningKey
+	err := r.db.Order("created_at").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]auth.StoredSigningKey, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, k.ToEntity())
+	}
+	return result, nil
+}
+
+func (r *GormRepository) DeleteSigningKeys(ids []string) error {
+	return r.db.Where("id IN ?", ids).Delete(&GormSigningKey{}).Error
+}
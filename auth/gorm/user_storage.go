@@ -1,128 +1,213 @@
-package gorm
-
-import (
-	"github.com/mohaali482/goAuth/auth"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-type GormUser struct {
-	gorm.Model
-	FirstName string
-	LastName  string
-	Username  string `gorm:"index"`
-	Phone     string
-	Password  string
-	Role      string
-	IsAdmin   bool `gorm:"default:false"`
-	IsActive  bool `gorm:"index,default:true"`
-}
-
-type GormRepository struct {
-	db *gorm.DB
-}
-
-func NewGormRepository(dbURL string) (*GormRepository, error) {
-	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-
-	db.AutoMigrate(&GormUser{})
-
-	return &GormRepository{db: db}, nil
-}
-
-func NewFromAuthUser(u auth.User) GormUser {
-	return GormUser{
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Username:  u.Username,
-		Phone:     u.Phone,
-		Password:  u.Password,
-		Role:      u.Role,
-		IsAdmin:   u.IsAdmin,
-		IsActive:  u.IsActive,
-	}
-}
-
-func (u GormUser) ToEntity() auth.User {
-	return auth.User{
-		ID:        int(u.ID),
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Username:  u.Username,
-		Phone:     u.Phone,
-		Password:  u.Password,
-		Role:      u.Role,
-		IsAdmin:   u.IsAdmin,
-		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		DeletedAt: u.DeletedAt.Time,
-	}
-}
-
-func (r *GormRepository) Create(u auth.User) (auth.User, error) {
-	user := NewFromAuthUser(u)
-	err := r.db.Create(&user).Error
-	if err != nil {
-		return auth.User{}, err
-	}
-	return user.ToEntity(), nil
-}
-
-func (r *GormRepository) GetAll() (auth.Users, error) {
-	var users []GormUser
-	err := r.db.Find(&users).Error
-	if err != nil {
-		return nil, err
-	}
-	var usersEntity auth.Users
-	for _, u := range users {
-		usersEntity = append(usersEntity, u.ToEntity())
-	}
-	return usersEntity, nil
-}
-
-func (r *GormRepository) GetByID(id int) (auth.User, error) {
-	var user GormUser
-	err := r.db.First(&user, id).Error
-	if err != nil {
-		return auth.User{}, err
-	}
-	return user.ToEntity(), nil
-}
-
-func (r *GormRepository) GetByUsername(username string) (auth.User, error) {
-	var user GormUser
-	err := r.db.Where("username = ?", username).First(&user).Error
-	if err != nil {
-		return auth.User{}, err
-	}
-	return user.ToEntity(), nil
-}
-
-func (r *GormRepository) GetByPhone(phone string) (auth.User, error) {
-	var user GormUser
-	err := r.db.Where("phone = ?", phone).First(&user).Error
-	if err != nil {
-		return auth.User{}, err
-	}
-	return user.ToEntity(), nil
-}
-
-func (r *GormRepository) Update(id int, u auth.User) (auth.User, error) {
-	user := NewFromAuthUser(u)
-	err := r.db.Model(&user).Where("id = ?", id).Updates(&user).Error
-	if err != nil {
-		return auth.User{}, err
-	}
-	return user.ToEntity(), nil
-}
-
-func (r *GormRepository) Delete(id int) error {
-	var user GormUser
-	err := r.db.Where("id = ?", id).Delete(&user).Error
-	return err
-}
+package gorm
+
+import (
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type GormUser struct {
+	gorm.Model
+	FirstName     string
+	LastName      string
+	Username      string `gorm:"index"`
+	Phone         string
+	Email         string `gorm:"index"`
+	Password      string
+	Role          string
+	IsAdmin       bool `gorm:"default:false"`
+	PhoneVerified bool `gorm:"default:false"`
+	EmailVerified bool `gorm:"default:false"`
+	// DeactivatedAt is set while the account is disabled. Users created
+	// before accounts could be disabled have no value and are active.
+	DeactivatedAt *time.Time `gorm:"index"`
+}
+
+type GormRepository struct {
+	db *gorm.DB
+}
+
+func NewGormRepository(dbURL string) (*GormRepository, error) {
+	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	db.AutoMigrate(&GormUser{}, &GormRefreshToken{}, &GormRevokedToken{}, &GormUserRevocation{}, &GormRole{}, &GormPasswordReset{}, &GormLoginAttempt{}, &GormMFA{}, &GormRecoveryCode{}, &GormPhoneVerification{}, &GormEmailVerification{}, &GormLoginCode{}, &GormWebAuthnCredential{}, &GormWebAuthnChallenge{}, &GormWebAuthnUser{}, &GormSession{}, &GormOpaqueToken{}, &GormOAuthClient{}, &GormAuthorizationCode{}, &GormSigningKey{}, &GormMigration{})
+
+	r := &GormRepository{db: db}
+	if err := r.migrateDeactivatedUsers(); err != nil {
+		return nil, err
+	}
+	if err := r.seedRoles(); err != nil {
+		return nil, err
+	}
+	if err := r.migrateOnce("grant_client_management", grantClientManagement); err != nil {
+		return nil, err
+	}
+	if err := r.migrateOnce("legacy_webauthn_user_handles", legacyWebAuthnUserHandles); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// migrateDeactivatedUsers moves accounts disabled through the former
+// is_active column to deactivated_at and drops the column
+func (r *GormRepository) migrateDeactivatedUsers() error {
+	if !r.db.Migrator().HasColumn(&GormUser{}, "is_active") {
+		return nil
+	}
+	err := r.db.Model(&GormUser{}).Unscoped().
+		Where("is_active = ? AND deactivated_at IS NULL", false).
+		Update("deactivated_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return r.db.Migrator().DropColumn(&GormUser{}, "is_active")
+}
+
+func NewFromAuthUser(u auth.User) GormUser {
+	return GormUser{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+		Phone:     u.Phone,
+		Email:     u.Email,
+		Password:  u.Password,
+		Role:      u.Role,
+		IsAdmin:   u.IsAdmin,
+	}
+}
+
+func (u GormUser) ToEntity() auth.User {
+	return auth.User{
+		ID:            int(u.ID),
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Username:      u.Username,
+		Phone:         u.Phone,
+		Email:         u.Email,
+		Password:      u.Password,
+		Role:          u.Role,
+		IsAdmin:       u.IsAdmin,
+		IsActive:      u.DeactivatedAt == nil,
+		PhoneVerified: u.PhoneVerified,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		DeletedAt:     u.DeletedAt.Time,
+	}
+}
+
+func (r *GormRepository) Create(u auth.User) (auth.User, error) {
+	user := NewFromAuthUser(u)
+	err := r.db.Create(&user).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) GetAll() (auth.Users, error) {
+	var users []GormUser
+	err := r.db.Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	var usersEntity auth.Users
+	for _, u := range users {
+		usersEntity = append(usersEntity, u.ToEntity())
+	}
+	return usersEntity, nil
+}
+
+func (r *GormRepository) GetByID(id int) (auth.User, error) {
+	var user GormUser
+	err := r.db.First(&user, id).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) GetByUsername(username string) (auth.User, error) {
+	var user GormUser
+	err := r.db.Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) GetByPhone(phone string) (auth.User, error) {
+	var user GormUser
+	err := r.db.Where("phone = ?", phone).First(&user).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) GetByEmail(email string) (auth.User, error) {
+	var user GormUser
+	err := r.db.Where("email = ? AND email_verified = ?", email, true).First(&user).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) Update(id int, u auth.User) (auth.User, error) {
+	user := NewFromAuthUser(u)
+	err := r.db.Model(&user).Where("id = ?", id).Updates(&user).Error
+	if err != nil {
+		return auth.User{}, err
+	}
+	return user.ToEntity(), nil
+}
+
+func (r *GormRepository) SetActive(id int, active bool) error {
+	var deactivatedAt *time.Time
+	if !active {
+		now := time.Now()
+		deactivatedAt = &now
+	}
+	result := r.db.Model(&GormUser{}).Where("id = ?", id).Update("deactivated_at", deactivatedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *GormRepository) SetPhoneVerified(id int, verified bool) error {
+	result := r.db.Model(&GormUser{}).Where("id = ?", id).Update("phone_verified", verified)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *GormRepository) SetEmailVerified(id int, verified bool) error {
+	result := r.db.Model(&GormUser{}).Where("id = ?", id).Update("email_verified", verified)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *GormRepository) Delete(id int) error {
+	var user GormUser
+	err := r.db.Where("id = ?", id).Delete(&user).Error
+	return err
+}
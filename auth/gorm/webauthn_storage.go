@@ -0,0 +1,195 @@
+package gorm
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormWebAuthnCredential struct {
+	ID                string `gorm:"primaryKey"`
+	UserID            int    `gorm:"index"`
+	Name              string
+	PublicKey         []byte
+	SignCount         int64
+	AAGUID            string
+	AttestationFormat string
+	SecondFactor      bool
+	CreatedAt         time.Time
+	LastUsedAt        *time.Time
+}
+
+// GormWebAuthnUser holds the handle identifying a user to authenticators
+type GormWebAuthnUser struct {
+	UserID int    `gorm:"primaryKey;autoIncrement:false"`
+	Handle []byte `gorm:"uniqueIndex"`
+}
+
+type GormWebAuthnChallenge struct {
+	ChallengeHash string `gorm:"primaryKey"`
+	UserID        int
+	Ceremony      string
+	ExpiresAt     time.Time `gorm:"index"`
+}
+
+func (c GormWebAuthnCredential) ToEntity() auth.WebAuthnCredential {
+	credential := auth.WebAuthnCredential{
+		ID:                c.ID,
+		UserID:            c.UserID,
+		Name:              c.Name,
+		PublicKey:         c.PublicKey,
+		SignCount:         uint32(c.SignCount),
+		AAGUID:            c.AAGUID,
+		AttestationFormat: c.AttestationFormat,
+		SecondFactor:      c.SecondFactor,
+		CreatedAt:         c.CreatedAt,
+	}
+	if c.LastUsedAt != nil {
+		credential.LastUsedAt = *c.LastUsedAt
+	}
+	return credential
+}
+
+func (r *GormRepository) CreateWebAuthnCredential(c auth.WebAuthnCredential) error {
+	credential := GormWebAuthnCredential{
+		ID:                c.ID,
+		UserID:            c.UserID,
+		Name:              c.Name,
+		PublicKey:         c.PublicKey,
+		SignCount:         int64(c.SignCount),
+		AAGUID:            c.AAGUID,
+		AttestationFormat: c.AttestationFormat,
+		SecondFactor:      c.SecondFactor,
+		CreatedAt:         c.CreatedAt,
+	}
+	return r.db.Create(&credential).Error
+}
+
+func (r *GormRepository) GetWebAuthnCredential(id string) (auth.WebAuthnCredential, error) {
+	var credential GormWebAuthnCredential
+	err := r.db.Where("id = ?", id).First(&credential).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.WebAuthnCredential{}, auth.ErrWebAuthnCredentialNotFound
+	}
+	if err != nil {
+		return auth.WebAuthnCredential{}, err
+	}
+	return credential.ToEntity(), nil
+}
+
+func (r *GormRepository) GetUserWebAuthnCredentials(userID int) ([]auth.WebAuthnCredential, error) {
+	var credentials []GormWebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	if err != nil {
+		return nil, err
+	}
+	entities := []auth.WebAuthnCredential{}
+	for _, c := range credentials {
+		entities = append(entities, c.ToEntity())
+	}
+	return entities, nil
+}
+
+func (r *GormRepository) UseWebAuthnCredential(id string, signCount uint32, usedAt time.Time) error {
+	result := r.db.Model(&GormWebAuthnCredential{}).
+		Where("id = ? AND (sign_count < ? OR (sign_count = 0 AND ? = 0))", id, signCount, signCount).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": usedAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrWebAuthnCloned
+	}
+	return nil
+}
+
+func (r *GormRepository) DeleteWebAuthnCredential(userID int, id string) error {
+	result := r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&GormWebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
+
+func (r *GormRepository) SaveWebAuthnChallenge(c auth.WebAuthnChallenge) error {
+	err := r.db.Where("expires_at < ?", time.Now()).Delete(&GormWebAuthnChallenge{}).Error
+	if err != nil {
+		return err
+	}
+	challenge := GormWebAuthnChallenge{
+		ChallengeHash: c.ChallengeHash,
+		UserID:        c.UserID,
+		Ceremony:      c.Ceremony,
+		ExpiresAt:     c.ExpiresAt,
+	}
+	return r.db.Create(&challenge).Error
+}
+
+func (r *GormRepository) ConsumeWebAuthnChallenge(challengeHash string) (auth.WebAuthnChallenge, error) {
+	var challenge GormWebAuthnChallenge
+	err := r.db.Where("challenge_hash = ? AND expires_at > ?", challengeHash, time.Now()).First(&challenge).Error
+	if err == gorm.ErrRecordNotFound {
+		return auth.WebAuthnChallenge{}, auth.ErrInvalidToken
+	}
+	if err != nil {
+		return auth.WebAuthnChallenge{}, err
+	}
+	result := r.db.Where("challenge_hash = ?", challengeHash).Delete(&GormWebAuthnChallenge{})
+	if result.Error != nil {
+		return auth.WebAuthnChallenge{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.WebAuthnChallenge{}, auth.ErrInvalidToken
+	}
+	return auth.WebAuthnChallenge{
+		ChallengeHash: challenge.ChallengeHash,
+		UserID:        challenge.UserID,
+		Ceremony:      challenge.Ceremony,
+		ExpiresAt:     challenge.ExpiresAt,
+	}, nil
+}
+
+func (r *GormRepository) GetWebAuthnUserHandle(userID int) ([]byte, error) {
+	var user GormWebAuthnUser
+	err := r.db.Where("user_id = ?", userID).First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, auth.ErrWebAuthnCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user.Handle, nil
+}
+
+func (r *GormRepository) CreateWebAuthnUserHandle(userID int, handle []byte) ([]byte, error) {
+	user := GormWebAuthnUser{UserID: userID, Handle: handle}
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetWebAuthnUserHandle(userID)
+}
+
+// legacyWebAuthnUserHandles keeps the user ID as the handle of users who
+// registered credentials before handles were random, since their
+// authenticators return it on login
+func legacyWebAuthnUserHandles(tx *gorm.DB) error {
+	var userIDs []int
+	err := tx.Model(&GormWebAuthnCredential{}).Distinct("user_id").Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		user := GormWebAuthnUser{UserID: id, Handle: []byte(strconv.Itoa(id))}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
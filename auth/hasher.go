@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mohaali482/goAuth/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrUnsupportedPasswordHasher = errors.New("unsupported password hasher")
+	ErrUnknownPasswordHash       = errors.New("unknown password hash format")
+)
+
+const (
+	HasherBcrypt   = "bcrypt"
+	HasherArgon2id = "argon2id"
+	HasherScrypt   = "scrypt"
+)
+
+const (
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+)
+
+// PasswordHasher hashes passwords into self describing strings so hashes of
+// different algorithms can be stored in the same column. Argon2id and scrypt
+// hashes use the PHC string format, bcrypt hashes keep their own $2a$ format.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether the hash was produced by another algorithm
+	// or with different parameters than the ones of the hasher
+	NeedsRehash(hash string) bool
+}
+
+// DefaultPasswordHasher is used by User.SetPassword
+var DefaultPasswordHasher PasswordHasher = BcryptHasher{Cost: bcrypt.DefaultCost}
+
+// NewPasswordHasher builds the hasher selected by Config.PasswordHasher
+func NewPasswordHasher(c *config.Config) (PasswordHasher, error) {
+	switch c.PasswordHasher {
+	case HasherBcrypt:
+		if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+			return nil, bcrypt.InvalidCostError(c.BcryptCost)
+		}
+		return BcryptHasher{Cost: c.BcryptCost}, nil
+	case HasherArgon2id:
+		if c.Argon2Time < 1 || c.Argon2Memory < 1 || c.Argon2Threads < 1 || c.Argon2Threads > 255 {
+			return nil, ErrUnsupportedPasswordHasher
+		}
+		return Argon2idHasher{
+			Time:    uint32(c.Argon2Time),
+			Memory:  uint32(c.Argon2Memory),
+			Threads: uint8(c.Argon2Threads),
+		}, nil
+	case HasherScrypt:
+		if c.ScryptCost < 1 || c.ScryptCost > 30 {
+			return nil, ErrUnsupportedPasswordHasher
+		}
+		return ScryptHasher{LogN: c.ScryptCost, R: 8, P: 1}, nil
+	}
+	return nil, ErrUnsupportedPasswordHasher
+}
+
+// VerifyPassword compares a password with a hash produced by any of the
+// supported algorithms. It returns ErrWrongCredentials when they don't match.
+func VerifyPassword(hash string, password string) error {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrWrongCredentials
+		}
+		return err
+	case strings.HasPrefix(hash, "$"+HasherArgon2id+"$"):
+		h, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		return compareKeys(key, argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, uint32(len(key))))
+	case strings.HasPrefix(hash, "$"+HasherScrypt+"$"):
+		h, salt, key, err := parseScrypt(hash)
+		if err != nil {
+			return err
+		}
+		derived, err := scrypt.Key([]byte(password), salt, 1<<h.LogN, h.R, h.P, len(key))
+		if err != nil {
+			return err
+		}
+		return compareKeys(key, derived)
+	}
+	return ErrUnknownPasswordHash
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id. Memory is in KiB.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, passwordKeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", HasherArgon2id, argon2.Version,
+		h.Memory, h.Time, h.Threads, encodeHashSegment(salt), encodeHashSegment(key)), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	current, _, _, err := parseArgon2id(hash)
+	return err != nil || current != h
+}
+
+func parseArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HasherArgon2id {
+		return Argon2idHasher{}, nil, nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, ErrUnknownPasswordHash
+	}
+	var h Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.Memory, &h.Time, &h.Threads); err != nil {
+		return Argon2idHasher{}, nil, nil, ErrUnknownPasswordHash
+	}
+	salt, key, err := decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, err
+	}
+	return h, salt, key, nil
+}
+
+// ScryptHasher hashes passwords with scrypt using N = 2^LogN
+type ScryptHasher struct {
+	LogN int
+	R    int
+	P    int
+}
+
+func (h ScryptHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), salt, 1<<h.LogN, h.R, h.P, passwordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", HasherScrypt,
+		h.LogN, h.R, h.P, encodeHashSegment(salt), encodeHashSegment(key)), nil
+}
+
+func (h ScryptHasher) NeedsRehash(hash string) bool {
+	current, _, _, err := parseScrypt(hash)
+	return err != nil || current != h
+}
+
+func parseScrypt(hash string) (ScryptHasher, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[1] != HasherScrypt {
+		return ScryptHasher{}, nil, nil, ErrUnknownPasswordHash
+	}
+	var h ScryptHasher
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &h.LogN, &h.R, &h.P); err != nil || h.LogN < 1 || h.LogN > 30 {
+		return ScryptHasher{}, nil, nil, ErrUnknownPasswordHash
+	}
+	salt, key, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return ScryptHasher{}, nil, nil, err
+	}
+	return h, salt, key, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func decodeSaltAndKey(encodedSalt string, encodedKey string) ([]byte, []byte, error) {
+	salt, err := base64.RawStdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, nil, ErrUnknownPasswordHash
+	}
+	return salt, key, nil
+}
+
+func compareKeys(expected []byte, derived []byte) error {
+	if subtle.ConstantTimeCompare(expected, derived) != 1 {
+		return ErrWrongCredentials
+	}
+	return nil
+}
+
+// encodeHashSegment encodes salts and keys as PHC strings do, with standard
+// base64 and no padding
+func encodeHashSegment(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	ErrIntrospectionDisabled = errors.New("token introspection is not available")
+	ErrInvalidClient         = errors.New("invalid client")
+)
+
+// Token type hints of introspection requests
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse is the answer of the introspection endpoint as
+// defined by RFC 7662. Inactive tokens only carry Active.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// AuthenticateIntrospectionClient checks the credentials of a protected
+// resource calling the introspection endpoint
+func (s *UserService) AuthenticateIntrospectionClient(clientID string, clientSecret string) error {
+	if s.Config.IntrospectionClientSecret == "" {
+		return ErrIntrospectionDisabled
+	}
+	validID := subtle.ConstantTimeCompare([]byte(clientID), []byte(s.Config.IntrospectionClientID))
+	validSecret := subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.Config.IntrospectionClientSecret))
+	if validID&validSecret != 1 {
+		return ErrInvalidClient
+	}
+	return nil
+}
+
+// Introspect reports whether the token is an active access or refresh token
+// and describes it. The hint only changes which type is tried first. Tokens
+// of inactive users are reported as inactive.
+func (s *UserService) Introspect(token string, tokenTypeHint string) IntrospectionResponse {
+	types := []string{Access, Refresh}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		types = []string{Refresh, Access}
+	}
+	for _, tokenType := range types {
+		claim, err := s.ValidateJWT(token, tokenType)
+		if err != nil {
+			continue
+		}
+		if err := s.checkActive(claim.ID); err != nil {
+			return IntrospectionResponse{}
+		}
+		response := IntrospectionResponse{
+			Active:   true,
+			Scope:    claim.Scope,
+			ClientID: claim.ClientID,
+			Username: claim.Username,
+			Sub:      claim.Subject,
+			Aud:      claim.Audience,
+			Iss:      claim.Issuer,
+			Jti:      claim.RegisteredClaims.ID,
+		}
+		if tokenType == Access {
+			response.TokenType = "Bearer"
+		}
+		if claim.ExpiresAt != nil {
+			response.Exp = claim.ExpiresAt.Unix()
+		}
+		if claim.IssuedAt != nil {
+			response.Iat = claim.IssuedAt.Unix()
+		}
+		if claim.NotBefore != nil {
+			response.Nbf = claim.NotBefore.Unix()
+		}
+		return response
+	}
+	return IntrospectionResponse{}
+}
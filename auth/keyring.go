@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const generatedRSAKeyBits = 2048
+
+// KeyRing holds the key currently used for signing together with retired
+// keys that are still accepted for verification until their retention
+// period has passed.
+type KeyRing struct {
+	mu        sync.RWMutex
+	current   SigningKey
+	retired   []retiredKey
+	retention time.Duration
+}
+
+type retiredKey struct {
+	key       SigningKey
+	retiredAt time.Time
+}
+
+func NewKeyRing(current SigningKey, retention time.Duration) *KeyRing {
+	return &KeyRing{
+		current:   current,
+		retention: retention,
+	}
+}
+
+func (r *KeyRing) Current() SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Get returns the key with the given kid if it is still valid for verification
+func (r *KeyRing) Get(kid string) (SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.current.ID == kid {
+		return r.current, true
+	}
+	now := time.Now()
+	for _, k := range r.retired {
+		if k.key.ID == kid && !r.expired(k, now) {
+			return k.key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// Keys returns every key that is still valid for verification, current first
+func (r *KeyRing) Keys() []SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := []SigningKey{r.current}
+	now := time.Now()
+	for i := len(r.retired) - 1; i >= 0; i-- {
+		if !r.expired(r.retired[i], now) {
+			keys = append(keys, r.retired[i].key)
+		}
+	}
+	return keys
+}
+
+// Rotate makes next the signing key. The previous key keeps verifying
+// tokens for the retention period of the ring.
+func (r *KeyRing) Rotate(next SigningKey) {
+	r.RotateAt(next, time.Now())
+}
+
+// RotateAt is Rotate for a key that became the signing key at the given time
+func (r *KeyRing) RotateAt(next SigningKey, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.retired = append(r.retired, retiredKey{key: r.current, retiredAt: at})
+	r.current = next
+
+	active := r.retired[:0]
+	for _, k := range r.retired {
+		if !r.expired(k, now) {
+			active = append(active, k)
+		}
+	}
+	r.retired = active
+}
+
+// Reset replaces the keys of the ring with the ones of other
+func (r *KeyRing) Reset(other *KeyRing) {
+	other.mu.RLock()
+	current, retired := other.current, append([]retiredKey(nil), other.retired...)
+	other.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = current
+	r.retired = retired
+}
+
+func (r *KeyRing) expired(k retiredKey, now time.Time) bool {
+	return now.Sub(k.retiredAt) > r.retention
+}
+
+// GenerateSigningKey creates a new random key for the signing method with a
+// random kid
+func GenerateSigningKey(method jwt.SigningMethod) (SigningKey, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	switch m := method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := make([]byte, m.Hash.Size()*2)
+		if _, err := rand.Read(secret); err != nil {
+			return SigningKey{}, err
+		}
+		return NewHMACKey(id, method, secret)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err := rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return NewSigningKey(id, method, private)
+	case *jwt.SigningMethodECDSA:
+		var curve elliptic.Curve
+		switch m.CurveBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		private, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return NewSigningKey(id, method, private)
+	case *jwt.SigningMethodEd25519:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return NewSigningKey(id, method, private)
+	}
+	return SigningKey{}, ErrUnsupportedSigningMethod
+}
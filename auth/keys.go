@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mohaali482/goAuth/config"
+)
+
+var (
+	ErrUnsupportedSigningMethod = errors.New("unsupported signing method")
+	ErrInvalidSigningKey        = errors.New("signing key does not match signing method")
+)
+
+const defaultHMACKeyID = "default"
+
+// SigningKey is a key used to sign and verify tokens. For HMAC methods the
+// private and public key are the same shared secret.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadSigningKey builds the signing key described by the configuration.
+// HMAC methods use Config.Secret, every other method reads the PEM encoded
+// private key from Config.PrivateKeyFile.
+func LoadSigningKey(c *config.Config) (SigningKey, error) {
+	method := jwt.GetSigningMethod(c.SigningMethod)
+	if method == nil {
+		return SigningKey{}, ErrUnsupportedSigningMethod
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		return NewHMACKey(c.KeyID, method, []byte(c.Secret))
+	}
+
+	pemBytes, err := os.ReadFile(c.PrivateKeyFile)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return ParseSigningKey(c.KeyID, method, pemBytes)
+}
+
+func NewHMACKey(id string, method jwt.SigningMethod, secret []byte) (SigningKey, error) {
+	if _, ok := method.(*jwt.SigningMethodHMAC); !ok {
+		return SigningKey{}, ErrInvalidSigningKey
+	}
+	if len(secret) == 0 {
+		return SigningKey{}, ErrInvalidSigningKey
+	}
+	if id == "" {
+		id = defaultHMACKeyID
+	}
+	return SigningKey{
+		ID:         id,
+		Method:     method,
+		PrivateKey: secret,
+		PublicKey:  secret,
+	}, nil
+}
+
+// ParseSigningKey parses a PEM encoded private key for an asymmetric signing
+// method. When id is empty the RFC 7638 thumbprint of the public key is used.
+func ParseSigningKey(id string, method jwt.SigningMethod, pemBytes []byte) (SigningKey, error) {
+	var (
+		private crypto.PrivateKey
+		err     error
+	)
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err = jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodECDSA:
+		private, err = jwt.ParseECPrivateKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodEd25519:
+		private, err = jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+	default:
+		return SigningKey{}, ErrUnsupportedSigningMethod
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return NewSigningKey(id, method, private)
+}
+
+func NewSigningKey(id string, method jwt.SigningMethod, private crypto.PrivateKey) (SigningKey, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, ErrInvalidSigningKey
+	}
+	key := SigningKey{
+		ID:         id,
+		Method:     method,
+		PrivateKey: private,
+		PublicKey:  signer.Public(),
+	}
+	if err := key.checkMethod(); err != nil {
+		return SigningKey{}, err
+	}
+	if key.ID == "" {
+		jwk, _ := key.JWK()
+		key.ID = jwk.Thumbprint()
+	}
+	return key, nil
+}
+
+func (k SigningKey) checkMethod() error {
+	switch m := k.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := k.PrivateKey.(*rsa.PrivateKey); ok {
+			return nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if pk, ok := k.PrivateKey.(*ecdsa.PrivateKey); ok && pk.Curve.Params().BitSize == m.CurveBits {
+			return nil
+		}
+	case *jwt.SigningMethodEd25519:
+		if _, ok := k.PrivateKey.(ed25519.PrivateKey); ok {
+			return nil
+		}
+	default:
+		return ErrUnsupportedSigningMethod
+	}
+	return ErrInvalidSigningKey
+}
+
+// JWK returns the public part of the key. HMAC keys are never published.
+func (k SigningKey) JWK() (JWK, bool) {
+	jwk := JWK{
+		Use: "sig",
+		Alg: k.Method.Alg(),
+		Kid: k.ID,
+	}
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeSegment(pub.N.Bytes())
+		jwk.E = encodeSegment(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = curveName(pub.Curve)
+		jwk.X = encodeSegment(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeSegment(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeSegment(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of the key.
+func (j JWK) Thumbprint() string {
+	var members []string
+	switch j.Kty {
+	case "RSA":
+		members = []string{`"e":"` + j.E + `"`, `"kty":"RSA"`, `"n":"` + j.N + `"`}
+	case "EC":
+		members = []string{`"crv":"` + j.Crv + `"`, `"kty":"EC"`, `"x":"` + j.X + `"`, `"y":"` + j.Y + `"`}
+	case "OKP":
+		members = []string{`"crv":"` + j.Crv + `"`, `"kty":"OKP"`, `"x":"` + j.X + `"`}
+	}
+	sum := sha256.Sum256([]byte("{" + strings.Join(members, ",") + "}"))
+	return encodeSegment(sum[:])
+}
+
+func curveName(c elliptic.Curve) string {
+	switch c {
+	case elliptic.P256():
+		return "P-256"
+	case elliptic.P384():
+		return "P-384"
+	case elliptic.P521():
+		return "P-521"
+	}
+	return c.Params().Name
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/x509"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// signingKeySyncInterval is how often stored keys are reloaded so keys
+	// rotated by another instance are used for signing
+	signingKeySyncInterval = time.Minute
+	// unknownKeySyncInterval limits reloads caused by tokens with an unknown
+	// kid, which may have been signed with a key just rotated elsewhere
+	unknownKeySyncInterval = 5 * time.Second
+)
+
+// StoredSigningKey is a key generated by RotateSigningKey as kept in a
+// SigningKeyRepository. Key holds the HMAC secret or the PKCS #8 encoded
+// private key.
+type StoredSigningKey struct {
+	ID        string
+	Algorithm string
+	Key       []byte
+	CreatedAt time.Time
+}
+
+type SigningKeyRepository interface {
+	CreateSigningKey(key StoredSigningKey) error
+	// GetSigningKeys returns every stored key, oldest first
+	GetSigningKeys() ([]StoredSigningKey, error)
+	DeleteSigningKeys(ids []string) error
+}
+
+// signingKeyStore rebuilds the key ring from the configured key followed by
+// the stored keys, each one retiring the previous at its creation time
+type signingKeyStore struct {
+	mu       sync.Mutex
+	repo     SigningKeyRepository
+	base     SigningKey
+	decoded  map[string]SigningKey
+	syncedAt time.Time
+}
+
+// WithSigningKeyRepository persists the keys generated by RotateSigningKey so
+// they survive restarts and are shared by every instance using the same
+// repository
+func WithSigningKeyRepository(r SigningKeyRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.signingKeys = &signingKeyStore{repo: r, decoded: map[string]SigningKey{}}
+	}
+}
+
+// SyncSigningKeys loads the stored signing keys into the key ring. The most
+// recent one becomes the signing key. Keys are also reloaded while signing
+// and verifying tokens, so calling it is only needed to fail early. Stored
+// keys that were replaced longer than the key retention ago no longer verify
+// any token and are deleted.
+//
+// Once a key has been rotated, the stored keys take precedence over the
+// configured one. Changing the configured key or signing method then has no
+// effect until the stored keys are deleted.
+func (s *UserService) SyncSigningKeys() error {
+	if s.signingKeys == nil {
+		return nil
+	}
+	ks := s.signingKeys
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.syncedAt = time.Now()
+	stored, err := ks.repo.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+	ring := NewKeyRing(ks.base, s.keyRetention())
+	cutoff := ks.syncedAt.Add(-s.keyRetention())
+	var expired []string
+	for i, k := range stored {
+		if i+1 < len(stored) && stored[i+1].CreatedAt.Before(cutoff) {
+			expired = append(expired, k.ID)
+			delete(ks.decoded, k.ID)
+			continue
+		}
+		key, err := ks.decode(k)
+		if err != nil {
+			return err
+		}
+		ring.RotateAt(key, k.CreatedAt)
+	}
+	s.keys.Reset(ring)
+	if len(expired) > 0 {
+		if err := ks.repo.DeleteSigningKeys(expired); err != nil {
+			log.Println("Error deleting expired signing keys. Error: ", err)
+		}
+	}
+	return nil
+}
+
+// syncSigningKeys reloads the stored keys unless they were loaded less than
+// interval ago. Errors keep the current keys until the next attempt.
+func (s *UserService) syncSigningKeys(interval time.Duration) {
+	if s.signingKeys == nil {
+		return
+	}
+	s.signingKeys.mu.Lock()
+	fresh := time.Since(s.signingKeys.syncedAt) < interval
+	s.signingKeys.mu.Unlock()
+	if !fresh {
+		s.SyncSigningKeys()
+	}
+}
+
+// storeSigningKey persists a key generated by RotateSigningKey
+func (s *UserService) storeSigningKey(key SigningKey, createdAt time.Time) error {
+	if s.signingKeys == nil {
+		return nil
+	}
+	encoded, err := encodeSigningKey(key)
+	if err != nil {
+		return err
+	}
+	err = s.signingKeys.repo.CreateSigningKey(StoredSigningKey{
+		ID:        key.ID,
+		Algorithm: key.Method.Alg(),
+		Key:       encoded,
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		return err
+	}
+	s.signingKeys.mu.Lock()
+	s.signingKeys.decoded[key.ID] = key
+	s.signingKeys.mu.Unlock()
+	return nil
+}
+
+// decode parses a stored key, caching the result since parsing private keys
+// is not free. It must be called with mu held.
+func (ks *signingKeyStore) decode(k StoredSigningKey) (SigningKey, error) {
+	if key, ok := ks.decoded[k.ID]; ok {
+		return key, nil
+	}
+	key, err := decodeSigningKey(k)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	ks.decoded[k.ID] = key
+	return key, nil
+}
+
+func encodeSigningKey(key SigningKey) ([]byte, error) {
+	if secret, ok := key.PrivateKey.([]byte); ok {
+		return secret, nil
+	}
+	return x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+}
+
+func decodeSigningKey(k StoredSigningKey) (SigningKey, error) {
+	method := jwt.GetSigningMethod(k.Algorithm)
+	if method == nil {
+		return SigningKey{}, ErrUnsupportedSigningMethod
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		return NewHMACKey(k.ID, method, k.Key)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(k.Key)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return NewSigningKey(k.ID, method, private)
+}
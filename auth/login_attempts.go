@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohaali482/goAuth/config"
+)
+
+// loginAttemptWindow is how long failed attempts are remembered after the
+// last one, which is also the longest possible lockout
+const loginAttemptWindow = 24 * time.Hour
+
+// LoginThrottledError is returned by Login, and the calls throttled like it,
+// while the username or client IP is backing off or locked out. It is
+// returned whether or not the account exists.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// RetryAfterSeconds is the value of the Retry-After header, rounded up
+func (e *LoginThrottledError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// LoginAttempts are the recent failed logins of a username or client IP
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+}
+
+type LoginAttemptStore interface {
+	GetLoginAttempts(key string) (LoginAttempts, error)
+	// RecordLoginFailure atomically counts a failed attempt at the given time,
+	// forgetting the failures that happened before resetBefore
+	RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (LoginAttempts, error)
+	ResetLoginAttempts(key string) error
+}
+
+// LoginThrottle decides how long a username or client IP has to wait after
+// failed logins. Every failure of a username doubles its wait starting from
+// Backoff. Reaching MaxAttempts, or IPMaxAttempts for a client IP, locks
+// logins for Lockout, doubling with every further failure.
+type LoginThrottle struct {
+	MaxAttempts   int
+	IPMaxAttempts int
+	Backoff       time.Duration
+	Lockout       time.Duration
+}
+
+func NewLoginThrottle(c *config.Config) LoginThrottle {
+	return LoginThrottle{
+		MaxAttempts:   c.LoginMaxAttempts,
+		IPMaxAttempts: c.LoginIPMaxAttempts,
+		Backoff:       time.Duration(c.LoginBackoff) * time.Second,
+		Lockout:       time.Duration(c.LoginLockoutTime) * time.Minute,
+	}
+}
+
+// retryAt returns when the next login is allowed after the given failures
+func (t LoginThrottle) retryAt(a LoginAttempts, maxAttempts int, backoff bool) time.Time {
+	var wait time.Duration
+	switch {
+	case a.Failures == 0:
+		return time.Time{}
+	case maxAttempts > 0 && a.Failures >= maxAttempts:
+		wait = exponential(t.Lockout, a.Failures-maxAttempts, loginAttemptWindow)
+	case backoff:
+		wait = exponential(t.Backoff, a.Failures-1, t.Lockout)
+	}
+	return a.LastFailure.Add(wait)
+}
+
+// exponential returns base * 2^n capped to max
+func exponential(base time.Duration, n int, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	wait := float64(base) * math.Pow(2, float64(n))
+	if max > 0 && wait > float64(max) {
+		return max
+	}
+	return time.Duration(wait)
+}
+
+// WithLoginAttemptStore enables brute force protection of Login
+func WithLoginAttemptStore(store LoginAttemptStore) UserServiceOption {
+	return func(s *UserService) {
+		s.loginAttempts = store
+	}
+}
+
+type loginAttemptKey struct {
+	key         string
+	maxAttempts int
+	backoff     bool
+}
+
+func (s *UserService) loginAttemptKeys(username string, clientIP string) []loginAttemptKey {
+	keys := []loginAttemptKey{{"user:" + strings.ToLower(username), s.loginThrottle.MaxAttempts, true}}
+	if clientIP != "" {
+		keys = append(keys, loginAttemptKey{"ip:" + clientIP, s.loginThrottle.IPMaxAttempts, false})
+	}
+	return keys
+}
+
+// checkLoginThrottle returns a *LoginThrottledError when any of the keys has
+// to wait before trying again
+func (s *UserService) checkLoginThrottle(keys []loginAttemptKey) error {
+	_, err := s.readLoginAttempts(keys)
+	return err
+}
+
+// readLoginAttempts returns the recent failures of every key, or a
+// *LoginThrottledError when any of the keys has to wait before trying again
+func (s *UserService) readLoginAttempts(keys []loginAttemptKey) (map[string]int, error) {
+	failures := map[string]int{}
+	if s.loginAttempts == nil {
+		return failures, nil
+	}
+	now := time.Now()
+	var retryAt time.Time
+	for _, k := range keys {
+		attempts, err := s.loginAttempts.GetLoginAttempts(k.key)
+		if err != nil {
+			return nil, err
+		}
+		if now.Sub(attempts.LastFailure) > loginAttemptWindow {
+			continue
+		}
+		failures[k.key] = attempts.Failures
+		if at := s.loginThrottle.retryAt(attempts, k.maxAttempts, k.backoff); at.After(retryAt) {
+			retryAt = at
+		}
+	}
+	if retryAt.After(now) {
+		return nil, &LoginThrottledError{RetryAfter: retryAt.Sub(now)}
+	}
+	return failures, nil
+}
+
+// startLoginAttempt checks the throttle like checkLoginThrottle and then
+// counts the attempt as a failure of the keys that back off before the
+// credentials are checked, so parallel attempts can't all get past the
+// backoff. Callers reset those keys when the attempt succeeds and record
+// failures of the other keys with recordLoginFailure.
+func (s *UserService) startLoginAttempt(keys []loginAttemptKey) error {
+	seen, err := s.readLoginAttempts(keys)
+	if err != nil || s.loginAttempts == nil {
+		return err
+	}
+	now := time.Now()
+	for _, k := range keys {
+		if !k.backoff {
+			continue
+		}
+		attempts, err := s.loginAttempts.RecordLoginFailure(k.key, now, now.Add(-loginAttemptWindow))
+		if err != nil {
+			return err
+		}
+		if attempts.Failures <= seen[k.key]+1 {
+			continue
+		}
+		// Parallel attempts were counted since the check, this one waits
+		// as if they had just failed
+		raced := LoginAttempts{Failures: attempts.Failures - 1, LastFailure: now}
+		if at := s.loginThrottle.retryAt(raced, k.maxAttempts, k.backoff); at.After(now) {
+			return &LoginThrottledError{RetryAfter: at.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt for the keys that were not
+// already counted by startLoginAttempt
+func (s *UserService) recordLoginFailure(keys []loginAttemptKey) error {
+	if s.loginAttempts == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, k := range keys {
+		if k.backoff {
+			continue
+		}
+		_, err := s.loginAttempts.RecordLoginFailure(k.key, now, now.Add(-loginAttemptWindow))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetLoginFailures forgets the failures of the username after a successful
+// login. Client IP failures are kept so one valid account can't be used to
+// keep guessing the passwords of others.
+func (s *UserService) resetLoginFailures(username string) error {
+	if s.loginAttempts == nil {
+		return nil
+	}
+	return s.loginAttempts.ResetLoginAttempts("user:" + strings.ToLower(username))
+}
+
+// UnlockLogin clears the failed login attempts of the user, lifting any
+// backoff or lockout
+func (s *UserService) UnlockLogin(userID int) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.resetLoginFailures(user.Username)
+}
+
+// MemoryLoginAttemptStore is a LoginAttemptStore kept in process memory. It
+// is only suitable when a single instance of the service is running.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempts
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: map[string]LoginAttempts{}}
+}
+
+func (m *MemoryLoginAttemptStore) GetLoginAttempts(key string) (LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempts, ok := m.attempts[key]
+	if !ok {
+		return LoginAttempts{Key: key}, nil
+	}
+	return attempts, nil
+}
+
+func (m *MemoryLoginAttemptStore) RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, attempts := range m.attempts {
+		if attempts.LastFailure.Before(resetBefore) {
+			delete(m.attempts, k)
+		}
+	}
+	attempts := m.attempts[key]
+	attempts.Key = key
+	attempts.Failures++
+	attempts.LastFailure = at
+	m.attempts[key] = attempts
+	return attempts, nil
+}
+
+func (m *MemoryLoginAttemptStore) ResetLoginAttempts(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrMFADisabled       = errors.New("two-factor authentication is not available")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+)
+
+const (
+	recoveryCodeCount = 10
+	qrCodeSize        = 256
+)
+
+// MFA is the second factor enrollment of a user. The secret is only used to
+// verify codes once Enabled is set by confirming a first code.
+type MFA struct {
+	UserID int
+	Secret string
+	// Enabled is set once the user confirmed the enrollment with a code
+	Enabled bool
+	// LastUsedStep is the time step of the last accepted code, which can't
+	// be used again
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type MFARepository interface {
+	// GetMFA returns an MFA with no secret when the user never enrolled
+	GetMFA(userID int) (MFA, error)
+	SaveMFA(mfa MFA) error
+	DeleteMFA(userID int) error
+	// UseTOTPStep atomically records the time step of an accepted code. It
+	// returns ErrInvalidMFACode if a code of that step or a later one was
+	// already used.
+	UseTOTPStep(userID int, step int64) error
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	// UseRecoveryCode atomically marks the code as used. It returns
+	// ErrInvalidMFACode if the code is unknown or already used.
+	UseRecoveryCode(userID int, codeHash string) error
+}
+
+// TOTPEnrollment is what an authenticator app needs to generate codes. QRCode
+// is a PNG image of URI.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode []byte `json:"qr_code"`
+}
+
+type MFACodeForm struct {
+	Code string `json:"code" validate:"required"`
+}
+
+func (f *MFACodeForm) Validate() error {
+	return Validate(f)
+}
+
+type MFAVerifyForm struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+func (f *MFAVerifyForm) Validate() error {
+	return Validate(f)
+}
+
+type MFADisableForm struct {
+	Password string `json:"password" validate:"required"`
+	// Code is a TOTP or recovery code, needed once the enrollment is confirmed
+	Code string `json:"code"`
+}
+
+func (f *MFADisableForm) Validate() error {
+	return Validate(f)
+}
+
+// WithMFARepository enables TOTP two-factor authentication
+func WithMFARepository(r MFARepository) UserServiceOption {
+	return func(s *UserService) {
+		s.mfa = r
+	}
+}
+
+// EnrollTOTP creates a new TOTP secret for the user. It only protects the
+// account once confirmed with ConfirmTOTP.
+func (s *UserService) EnrollTOTP(userID int) (TOTPEnrollment, error) {
+	if s.mfa == nil {
+		return TOTPEnrollment{}, ErrMFADisabled
+	}
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if mfa.Enabled {
+		return TOTPEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if err := s.mfa.SaveMFA(MFA{UserID: userID, Secret: secret}); err != nil {
+		return TOTPEnrollment{}, err
+	}
+	uri := TOTPURI(s.Config.Issuer, user.Username, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	return TOTPEnrollment{Secret: secret, URI: uri, QRCode: png}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the
+// enrollment works with a code. It returns the recovery codes of the user,
+// which are only ever shown this once.
+func (s *UserService) ConfirmTOTP(userID int, code string) ([]string, error) {
+	if s.mfa == nil {
+		return nil, ErrMFADisabled
+	}
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if mfa.Secret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	step, ok := matchTOTP(mfa.Secret, normalizeMFACode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	mfa.Enabled = true
+	mfa.LastUsedStep = step
+	if err := s.mfa.SaveMFA(mfa); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA removes the second factor of the calling user after verifying
+// the password and, once enabled, a current TOTP or recovery code. Both are
+// throttled like logins. Every session of the user except the calling one is
+// ended, as they may have been opened by whoever disabled it.
+func (s *UserService) DisableMFA(claim JWTClaim, password string, code string) error {
+	if s.mfa == nil {
+		return ErrMFADisabled
+	}
+	user, err := s.repo.GetByID(claim.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCurrentPassword(user, password); err != nil {
+		return err
+	}
+	mfa, err := s.mfa.GetMFA(user.ID)
+	if err != nil {
+		return err
+	}
+	if mfa.Enabled {
+		keys := mfaAttemptKeys(user.ID, s.loginThrottle.MaxAttempts)
+		if err := s.startLoginAttempt(keys); err != nil {
+			return err
+		}
+		if err := s.checkSecondFactor(user.ID, code); err != nil {
+			return err
+		}
+		s.resetMFAAttempts(keys)
+	}
+	if err := s.mfa.DeleteMFA(user.ID); err != nil {
+		return err
+	}
+	return s.endSessions(user.ID, claim.Family)
+}
+
+// MFARequired reports whether the user has to complete a second factor with
+// VerifyMFA or FinishWebAuthnMFA before tokens are issued
+func (s *UserService) MFARequired(user User) (bool, error) {
+	if s.mfa != nil {
+		mfa, err := s.mfa.GetMFA(user.ID)
+		if err != nil {
+			return false, err
+		}
+		if mfa.Enabled {
+			return true, nil
+		}
+	}
+	credentials, err := s.secondFactorCredentials(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// GenerateMFAToken issues the short lived token a client exchanges for a
+// token pair with VerifyMFA after a successful password login
+func (s *UserService) GenerateMFAToken(user User) (string, error) {
+	claim, err := s.newClaim(user, MFAPending, "", time.Duration(s.Config.MFATokenExpTime)*time.Minute)
+	if err != nil {
+		return "", err
+	}
+	return s.sign(claim)
+}
+
+// VerifyMFA completes a login started with a password. The code is either a
+// TOTP code or one of the recovery codes of the user. Failed codes are
+// throttled like failed logins. The token can only be used once when a
+// RevocationStore is configured, otherwise it stays valid until it expires.
+func (s *UserService) VerifyMFA(mfaToken string, code string) (User, error) {
+	if s.mfa == nil {
+		return User{}, ErrMFADisabled
+	}
+	claim, err := s.ValidateJWT(mfaToken, MFAPending)
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	keys := mfaAttemptKeys(claim.ID, s.loginThrottle.MaxAttempts)
+	if err := s.startLoginAttempt(keys); err != nil {
+		return User{}, err
+	}
+	user, err := s.repo.GetByID(claim.ID)
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	if err := s.checkCanLogin(user); err != nil {
+		return User{}, err
+	}
+
+	if err := s.checkSecondFactor(user.ID, code); err != nil {
+		return User{}, err
+	}
+	s.resetMFAAttempts(keys)
+	if s.revocations != nil {
+		if err := s.revocations.RevokeToken(claim.RegisteredClaims.ID, claim.ExpiresAt.Time); err != nil {
+			return User{}, err
+		}
+	}
+	return user, nil
+}
+
+// mfaAttemptKeys are the login attempt keys counting the second factor codes
+// tried for the user
+func mfaAttemptKeys(userID int, maxAttempts int) []loginAttemptKey {
+	return []loginAttemptKey{{fmt.Sprintf("mfa:%d", userID), maxAttempts, true}}
+}
+
+func (s *UserService) resetMFAAttempts(keys []loginAttemptKey) {
+	if s.loginAttempts == nil {
+		return
+	}
+	for _, k := range keys {
+		if err := s.loginAttempts.ResetLoginAttempts(k.key); err != nil {
+			log.Println("Error resetting failed two-factor codes. Error: ", err)
+		}
+	}
+}
+
+func (s *UserService) checkSecondFactor(userID int, code string) error {
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return ErrMFANotEnrolled
+	}
+	code = normalizeMFACode(code)
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(mfa.Secret, code, time.Now())
+		if !ok || step <= mfa.LastUsedStep {
+			return ErrInvalidMFACode
+		}
+		return s.mfa.UseTOTPStep(userID, step)
+	}
+	return s.mfa.UseRecoveryCode(userID, hashToken(code))
+}
+
+// newRecoveryCodes returns the recovery codes to show to the user and the
+// hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomHex(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = code[0:5] + "-" + code[5:10] + "-" + code[10:15] + "-" + code[15:20]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeMFACode removes the separators users type or copy along with codes
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
@@ -0,0 +1,31 @@
+package auth
+
+import "log"
+
+const (
+	NotificationPasswordReset   = "password_reset"
+	NotificationDuplicateSignup = "duplicate_signup"
+)
+
+// Notification is a message sent to a user out of band, for example by
+// email. Data holds the values a template based notifier needs, like tokens
+// and links.
+type Notification struct {
+	Kind    string
+	Subject string
+	Body    string
+	Data    map[string]string
+}
+
+type Notifier interface {
+	Notify(user User, n Notification) error
+}
+
+// LogNotifier writes notifications to the standard logger. It is meant for
+// local development only as notifications may contain secrets.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(user User, n Notification) error {
+	log.Printf("Notification %q for user %d (%s): %s\n%s", n.Kind, user.ID, user.Username, n.Subject, n.Body)
+	return nil
+}
@@ -0,0 +1,452 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOAuthDisabled           = errors.New("oauth is not available")
+	ErrInvalidRedirectURI      = errors.New("redirect uri is not registered for the client")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	ErrUnsupportedGrantType    = errors.New("unsupported grant type")
+	ErrPKCERequired            = errors.New("a code challenge with the S256 method is required")
+	ErrInvalidGrant            = errors.New("invalid authorization grant")
+	ErrAccessDenied            = errors.New("the user denied the authorization request")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrInsufficientScope       = errors.New("the access token was not granted the required scope")
+)
+
+// OAuth 2.0 values accepted by the authorization server
+const (
+	ResponseTypeCode           = "code"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	CodeChallengeMethodS256    = "S256"
+)
+
+// ScopeProfile lets a client read the profile of the user
+const ScopeProfile = "profile"
+
+// SupportedScopes are the scopes clients can be granted. Access tokens of
+// clients are only accepted on routes that require one of their scopes.
+var SupportedScopes = []string{ScopeProfile}
+
+const (
+	authorizationCodeSize = 32
+	clientSecretSize      = 32
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// OAuthClient is an application allowed to delegate login to the
+// authorization server. Public clients, like single page and native apps,
+// have no secret and rely on PKCE alone.
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Secret       string    `json:"client_secret,omitempty"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuthorizationCode is a code issued by the authorization endpoint, stored
+// hashed, along with the request it answers
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int
+	RedirectURI   string
+	CodeChallenge string
+	Scope         string
+	ExpiresAt     time.Time
+}
+
+type OAuthRepository interface {
+	CreateOAuthClient(client OAuthClient) error
+	// GetOAuthClient returns ErrInvalidClient if the client is unknown
+	GetOAuthClient(id string) (OAuthClient, error)
+	GetOAuthClients() ([]OAuthClient, error)
+	DeleteOAuthClient(id string) error
+	CreateAuthorizationCode(code AuthorizationCode) error
+	// ConsumeAuthorizationCode atomically deletes the code. It returns
+	// ErrInvalidGrant if the code is unknown, expired or already used.
+	ConsumeAuthorizationCode(codeHash string) (AuthorizationCode, error)
+}
+
+type OAuthClientForm struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+	Public       bool     `json:"public"`
+}
+
+func (f *OAuthClientForm) Validate() error {
+	return Validate(f)
+}
+
+// AuthorizationRequest holds the parameters of a request to the
+// authorization endpoint
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// AuthorizationConsentForm is the answer of the user to an authorization
+// request
+type AuthorizationConsentForm struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// TokenRequest holds the parameters of a request to the token endpoint
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// WithOAuthRepository enables the OAuth 2.0 authorization server
+func WithOAuthRepository(r OAuthRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.oauth = r
+	}
+}
+
+// RegisterOAuthClient registers a new client. The secret of confidential
+// clients is only returned here.
+func (s *UserService) RegisterOAuthClient(form OAuthClientForm) (OAuthClient, error) {
+	if s.oauth == nil {
+		return OAuthClient{}, ErrOAuthDisabled
+	}
+	for _, redirectURI := range form.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return OAuthClient{}, ErrInvalidRedirectURI
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	client := OAuthClient{
+		ID:           id,
+		Name:         form.Name,
+		RedirectURIs: form.RedirectURIs,
+		Public:       form.Public,
+		CreatedAt:    time.Now(),
+	}
+	if !client.Public {
+		client.Secret, err = randomHex(clientSecretSize)
+		if err != nil {
+			return OAuthClient{}, err
+		}
+		client.SecretHash = hashToken(client.Secret)
+	}
+	if err := s.oauth.CreateOAuthClient(client); err != nil {
+		return OAuthClient{}, err
+	}
+	return client, nil
+}
+
+func (s *UserService) GetOAuthClients() ([]OAuthClient, error) {
+	if s.oauth == nil {
+		return nil, ErrOAuthDisabled
+	}
+	return s.oauth.GetOAuthClients()
+}
+
+func (s *UserService) DeleteOAuthClient(id string) error {
+	if s.oauth == nil {
+		return ErrOAuthDisabled
+	}
+	return s.oauth.DeleteOAuthClient(id)
+}
+
+// AuthenticateOAuthClient checks the credentials a client presents to the
+// token endpoint. Public clients only present their id.
+func (s *UserService) AuthenticateOAuthClient(clientID string, clientSecret string) (OAuthClient, error) {
+	if s.oauth == nil {
+		return OAuthClient{}, ErrOAuthDisabled
+	}
+	client, err := s.oauth.GetOAuthClient(clientID)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	if client.Public {
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return OAuthClient{}, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ValidateAuthorizationRequest checks a request to the authorization endpoint
+// and returns the client asking for authorization. With ErrInvalidClient or
+// ErrInvalidRedirectURI the user must not be redirected back to the client.
+func (s *UserService) ValidateAuthorizationRequest(r AuthorizationRequest) (OAuthClient, error) {
+	if s.oauth == nil {
+		return OAuthClient{}, ErrOAuthDisabled
+	}
+	client, err := s.oauth.GetOAuthClient(r.ClientID)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	if !client.allowsRedirectURI(r.RedirectURI) {
+		return OAuthClient{}, ErrInvalidRedirectURI
+	}
+	if r.ResponseType != ResponseTypeCode {
+		return OAuthClient{}, ErrUnsupportedResponseType
+	}
+	if r.CodeChallengeMethod != CodeChallengeMethodS256 || !validCodeChallenge(r.CodeChallenge) {
+		return OAuthClient{}, ErrPKCERequired
+	}
+	if _, err := ParseScope(r.Scope); err != nil {
+		return OAuthClient{}, err
+	}
+	return client, nil
+}
+
+// Authorize issues an authorization code for the user who approved the
+// request and returns where to redirect the user with it
+func (s *UserService) Authorize(userID int, r AuthorizationRequest) (string, error) {
+	if _, err := s.ValidateAuthorizationRequest(r); err != nil {
+		return "", err
+	}
+	scope, err := ParseScope(r.Scope)
+	if err != nil {
+		return "", err
+	}
+	code, err := randomHex(authorizationCodeSize)
+	if err != nil {
+		return "", err
+	}
+	err = s.oauth.CreateAuthorizationCode(AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      r.ClientID,
+		UserID:        userID,
+		RedirectURI:   r.RedirectURI,
+		CodeChallenge: r.CodeChallenge,
+		Scope:         scope,
+		ExpiresAt:     time.Now().Add(time.Duration(s.Config.OAuthCodeExpTime) * time.Minute),
+	})
+	if err != nil {
+		return "", err
+	}
+	return authorizationRedirect(r, url.Values{"code": {code}}), nil
+}
+
+// DenyAuthorization returns where to redirect the user who denied the
+// request
+func (s *UserService) DenyAuthorization(r AuthorizationRequest) (string, error) {
+	if _, err := s.ValidateAuthorizationRequest(r); err != nil {
+		return "", err
+	}
+	return s.AuthorizationErrorRedirect(r, ErrAccessDenied), nil
+}
+
+// AuthorizationErrorRedirect returns where to redirect the user to report
+// the error to the client. It must only be used once the client and the
+// redirect uri are known to be valid.
+func (s *UserService) AuthorizationErrorRedirect(r AuthorizationRequest, err error) string {
+	return authorizationRedirect(r, url.Values{
+		"error":             {OAuthErrorCode(err)},
+		"error_description": {err.Error()},
+	})
+}
+
+// GrantToken answers a request to the token endpoint of an authenticated
+// client
+func (s *UserService) GrantToken(client OAuthClient, r TokenRequest, userAgent string, clientIP string) (TokenResponse, error) {
+	var tokens map[string]string
+	var err error
+	switch r.GrantType {
+	case GrantTypeAuthorizationCode:
+		tokens, err = s.exchangeAuthorizationCode(client, r, userAgent, clientIP)
+	case GrantTypeRefreshToken:
+		tokens, err = s.refresh(r.RefreshToken, client.ID, userAgent, clientIP)
+	default:
+		err = ErrUnsupportedGrantType
+	}
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	claim, err := s.parseToken(tokens["access"])
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{
+		AccessToken:  tokens["access"],
+		TokenType:    "Bearer",
+		ExpiresIn:    s.Config.AccessExpTime * 60,
+		RefreshToken: tokens["refresh"],
+		Scope:        claim.Scope,
+	}, nil
+}
+
+func (s *UserService) exchangeAuthorizationCode(client OAuthClient, r TokenRequest, userAgent string, clientIP string) (map[string]string, error) {
+	if r.Code == "" {
+		return nil, ErrInvalidGrant
+	}
+	code, err := s.oauth.ConsumeAuthorizationCode(hashToken(r.Code))
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != client.ID || code.RedirectURI != r.RedirectURI || !verifyCodeChallenge(r.CodeVerifier, code.CodeChallenge) {
+		return nil, ErrInvalidGrant
+	}
+	user, err := s.repo.GetByID(code.UserID)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	return s.issueTokens(user, client.ID, code.Scope, userAgent, clientIP)
+}
+
+// OAuthErrorCode returns the OAuth 2.0 error code reported for the error
+func OAuthErrorCode(err error) string {
+	switch err {
+	case ErrInvalidClient:
+		return "invalid_client"
+	case ErrInvalidGrant, ErrInvalidToken, ErrRefreshTokenReused, ErrUserInactive:
+		return "invalid_grant"
+	case ErrUnsupportedGrantType:
+		return "unsupported_grant_type"
+	case ErrUnsupportedResponseType:
+		return "unsupported_response_type"
+	case ErrAccessDenied:
+		return "access_denied"
+	case ErrInvalidRedirectURI, ErrPKCERequired:
+		return "invalid_request"
+	case ErrInvalidScope:
+		return "invalid_scope"
+	case ErrInsufficientScope:
+		return "insufficient_scope"
+	case ErrOAuthDisabled:
+		return "temporarily_unavailable"
+	}
+	return "server_error"
+}
+
+// ParseScope checks a space separated list of scopes requested by a client
+// and returns the scopes granted for it. Clients asking for no scope are
+// granted ScopeProfile.
+func ParseScope(scope string) (string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return ScopeProfile, nil
+	}
+	granted := make([]string, 0, len(requested))
+	for _, sc := range requested {
+		if !containsScope(SupportedScopes, sc) {
+			return "", ErrInvalidScope
+		}
+		if !containsScope(granted, sc) {
+			granted = append(granted, sc)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// AllowsScope tells whether the token may be used on a route requiring the
+// scope. Tokens of first party logins are not restricted, while tokens issued
+// to clients need the scope to have been granted. An empty scope only admits
+// first party tokens.
+func (c JWTClaim) AllowsScope(scope string) bool {
+	if c.ClientID == "" {
+		return true
+	}
+	return scope != "" && containsScope(strings.Fields(c.Scope), scope)
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, sc := range scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (c OAuthClient) allowsRedirectURI(redirectURI string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if redirectURI == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizationRedirect adds the response parameters and the state of the
+// request to its redirect uri
+func authorizationRedirect(r AuthorizationRequest, params url.Values) string {
+	redirectURL, err := url.Parse(r.RedirectURI)
+	if err != nil {
+		return r.RedirectURI
+	}
+	query := redirectURL.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if r.State != "" {
+		query.Set("state", r.State)
+	}
+	redirectURL.RawQuery = query.Encode()
+	return redirectURL.String()
+}
+
+// validRedirectURI accepts absolute uris without a fragment, which includes
+// the custom schemes of native apps
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	return err == nil && u.IsAbs() && u.Fragment == ""
+}
+
+func validCodeChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// verifyCodeChallenge checks the PKCE code verifier against the S256
+// challenge of the authorization request
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	for _, c := range verifier {
+		if !isUnreserved(c) {
+			return false
+		}
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func isUnreserved(c rune) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+// RFC 7636 appendix B
+const (
+	rfc7636Verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfc7636Challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		ok        bool
+	}{
+		{"RFC 7636 vector", rfc7636Verifier, rfc7636Challenge, true},
+		{"wrong verifier", "eBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", rfc7636Challenge, false},
+		{"wrong challenge", rfc7636Verifier, "F9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", false},
+		{"padded challenge", rfc7636Verifier, rfc7636Challenge + "=", false},
+		{"plain method", rfc7636Verifier, rfc7636Verifier, false},
+		{"empty verifier", "", rfc7636Challenge, false},
+		{"short verifier", rfc7636Verifier[:42], rfc7636Challenge, false},
+		{"long verifier", strings.Repeat("a", maxCodeVerifierLength+1), rfc7636Challenge, false},
+		{"reserved character", rfc7636Verifier[:42] + "+", rfc7636Challenge, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := verifyCodeChallenge(tt.verifier, tt.challenge); ok != tt.ok {
+				t.Errorf("verifyCodeChallenge(%q, %q) = %v, want %v", tt.verifier, tt.challenge, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidCodeChallenge(t *testing.T) {
+	tests := []struct {
+		challenge string
+		ok        bool
+	}{
+		{rfc7636Challenge, true},
+		{"", false},
+		{rfc7636Challenge[:42], false},
+		{rfc7636Challenge + "A", false},
+		{rfc7636Challenge + "=", false},
+		{"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM", false},
+	}
+	for _, tt := range tests {
+		if ok := validCodeChallenge(tt.challenge); ok != tt.ok {
+			t.Errorf("validCodeChallenge(%q) = %v, want %v", tt.challenge, ok, tt.ok)
+		}
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  string
+		err   error
+	}{
+		{"", ScopeProfile, nil},
+		{"  ", ScopeProfile, nil},
+		{"profile", "profile", nil},
+		{"profile profile", "profile", nil},
+		{"profile admin", "", ErrInvalidScope},
+		{"Profile", "", ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		got, err := ParseScope(tt.scope)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseScope(%q) = %q, %v, want %q, %v", tt.scope, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAllowsScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		claim JWTClaim
+		scope string
+		ok    bool
+	}{
+		{"first party token on unscoped route", JWTClaim{}, "", true},
+		{"first party token on scoped route", JWTClaim{}, ScopeProfile, true},
+		{"client token on unscoped route", JWTClaim{ClientID: "app", Scope: ScopeProfile}, "", false},
+		{"client token with scope", JWTClaim{ClientID: "app", Scope: ScopeProfile}, ScopeProfile, true},
+		{"client token without scope", JWTClaim{ClientID: "app"}, ScopeProfile, false},
+	}
+	for _, tt := range tests {
+		if ok := tt.claim.AllowsScope(tt.scope); ok != tt.ok {
+			t.Errorf("%s: AllowsScope(%q) = %v, want %v", tt.name, tt.scope, ok, tt.ok)
+		}
+	}
+}
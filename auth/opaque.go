@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mohaali482/goAuth/config"
+)
+
+var ErrOpaqueTokensUnavailable = errors.New("opaque tokens are not available")
+
+const opaqueTokenSize = 32
+
+// OpaqueToken is an access token issued as a random string instead of a JWT.
+// Only the hash of the token is stored, along with the claims a JWT would
+// carry.
+type OpaqueToken struct {
+	TokenHash string
+	ID        string
+	UserID    int
+	Username  string
+	Role      string
+	IsAdmin   bool
+	Family    string
+	ClientID  string
+	Scope     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+type OpaqueTokenRepository interface {
+	CreateOpaqueToken(token OpaqueToken) error
+	// GetOpaqueToken returns ErrInvalidToken if the token is unknown or
+	// expired
+	GetOpaqueToken(tokenHash string) (OpaqueToken, error)
+	DeleteOpaqueToken(tokenHash string) error
+	DeleteOpaqueTokenFamily(family string) error
+	// DeleteUserOpaqueTokens deletes every token of the user except the ones
+	// in exceptFamily, which may be empty
+	DeleteUserOpaqueTokens(userID int, exceptFamily string) error
+}
+
+// WithOpaqueTokenRepository sets where opaque access tokens are stored. They
+// are issued when Config.TokenFormat is opaque and accepted by ValidateJWT
+// whenever the repository is set.
+func WithOpaqueTokenRepository(r OpaqueTokenRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.opaqueTokens = r
+	}
+}
+
+// issueAccessToken turns the claim into a signed JWT or an opaque token
+// depending on Config.TokenFormat
+func (s *UserService) issueAccessToken(claim JWTClaim) (string, error) {
+	if s.Config.TokenFormat != config.TokenFormatOpaque {
+		return s.sign(claim)
+	}
+	if s.opaqueTokens == nil {
+		return "", ErrOpaqueTokensUnavailable
+	}
+	token, err := randomHex(opaqueTokenSize)
+	if err != nil {
+		return "", err
+	}
+	err = s.opaqueTokens.CreateOpaqueToken(OpaqueToken{
+		TokenHash: hashToken(token),
+		ID:        claim.RegisteredClaims.ID,
+		UserID:    claim.ID,
+		Username:  claim.Username,
+		Role:      claim.Role,
+		IsAdmin:   claim.IsAdmin,
+		Family:    claim.Family,
+		ClientID:  claim.ClientID,
+		Scope:     claim.Scope,
+		IssuedAt:  claim.IssuedAt.Time,
+		ExpiresAt: claim.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// parseToken reads the claims of a JWT or of an opaque token from the store
+func (s *UserService) parseToken(token string) (JWTClaim, error) {
+	if s.opaqueTokens == nil || !isOpaqueToken(token) {
+		return s.parseJWT(token)
+	}
+	t, err := s.opaqueTokens.GetOpaqueToken(hashToken(token))
+	if err != nil {
+		return JWTClaim{}, err
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return JWTClaim{}, ErrInvalidToken
+	}
+	return JWTClaim{
+		ID:       t.UserID,
+		Username: t.Username,
+		Role:     t.Role,
+		IsAdmin:  t.IsAdmin,
+		Token:    Access,
+		Family:   t.Family,
+		ClientID: t.ClientID,
+		Scope:    t.Scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        t.ID,
+			Issuer:    s.Config.Issuer,
+			Subject:   strconv.Itoa(t.UserID),
+			Audience:  s.Config.Audience,
+			IssuedAt:  jwt.NewNumericDate(t.IssuedAt),
+			NotBefore: jwt.NewNumericDate(t.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(t.ExpiresAt),
+		},
+	}, nil
+}
+
+func (s *UserService) deleteOpaqueTokenFamily(family string) error {
+	if s.opaqueTokens == nil || family == "" {
+		return nil
+	}
+	return s.opaqueTokens.DeleteOpaqueTokenFamily(family)
+}
+
+func (s *UserService) deleteUserOpaqueTokens(userID int, exceptFamily string) error {
+	if s.opaqueTokens == nil {
+		return nil
+	}
+	return s.opaqueTokens.DeleteUserOpaqueTokens(userID, exceptFamily)
+}
+
+// isOpaqueToken tells opaque tokens apart from JWTs, which always contain
+// dots
+func isOpaqueToken(token string) bool {
+	return token != "" && !strings.Contains(token, ".")
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mohaali482/goAuth/config"
+)
+
+// bcryptMaxBytes is the number of bytes bcrypt takes into account, the rest
+// of a longer password is ignored
+const bcryptMaxBytes = 72
+
+// minEmailLocalLength is the shortest email local part DisallowUserInfo
+// checks for, shorter ones would reject too many passwords
+const minEmailLocalLength = 3
+
+// PasswordPolicy is the set of rules new passwords must follow. A zero value
+// field disables the corresponding rule. Lengths count characters.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes is the longest UTF-8 encoded password the hasher takes into
+	// account in full
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DisallowUserInfo rejects passwords containing the username, phone
+	// number or email address of the user
+	DisallowUserInfo bool
+	// MinScore is the minimum PasswordStrength score, from 0 to 4
+	MinScore int
+	// Breached rejects passwords found in a breach corpus when set
+	Breached BreachedPasswordChecker
+}
+
+// PasswordPolicyError lists every rule a password violates. Field is the name
+// of the request field holding the password.
+type PasswordPolicyError struct {
+	Field      string
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password policy violated: " + strings.Join(e.Violations, ", ")
+}
+
+// NewPasswordPolicy builds the policy described by the configuration. With
+// the bcrypt hasher passwords are also limited to the bytes bcrypt supports.
+func NewPasswordPolicy(c *config.Config) PasswordPolicy {
+	p := PasswordPolicy{
+		MinLength:        c.PasswordMinLength,
+		MaxLength:        c.PasswordMaxLength,
+		RequireUpper:     c.PasswordRequireUpper,
+		RequireLower:     c.PasswordRequireLower,
+		RequireDigit:     c.PasswordRequireDigit,
+		RequireSymbol:    c.PasswordRequireSymbol,
+		DisallowUserInfo: c.PasswordDisallowUserInfo,
+		MinScore:         c.PasswordMinScore,
+	}
+	if c.PasswordHasher == HasherBcrypt {
+		p.MaxBytes = bcryptMaxBytes
+	}
+	return p
+}
+
+// Check validates the password of the user. It returns a *PasswordPolicyError
+// for the "password" field if any rule is violated.
+func (p PasswordPolicy) Check(user User, password string) error {
+	var violations []string
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("This field must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("This field must be at most %d characters long", p.MaxLength))
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, fmt.Sprintf("This field must be at most %d bytes long, non-ASCII characters taking several bytes", p.MaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "This field must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "This field must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "This field must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "This field must contain a symbol")
+	}
+
+	if p.DisallowUserInfo {
+		lowered := strings.ToLower(password)
+		if user.Username != "" && strings.Contains(lowered, strings.ToLower(user.Username)) {
+			violations = append(violations, "This field must not contain the username")
+		}
+		if phone := strings.TrimPrefix(user.Phone, "+"); phone != "" && strings.Contains(lowered, phone) {
+			violations = append(violations, "This field must not contain the phone number")
+		}
+		if local, _, _ := strings.Cut(user.Email, "@"); len(local) >= minEmailLocalLength && strings.Contains(lowered, strings.ToLower(local)) {
+			violations = append(violations, "This field must not contain the email address")
+		}
+	}
+
+	if p.MinScore > 0 && PasswordStrength(password, user.Username, user.FirstName, user.LastName) < p.MinScore {
+		violations = append(violations, "This password is too easy to guess")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.IsBreached(password)
+		if err != nil {
+			log.Println("Error checking breached passwords. Error: ", err)
+		}
+		if breached {
+			violations = append(violations, "This password has appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Field: "password", Violations: violations}
+	}
+	return nil
+}
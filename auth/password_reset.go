@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// PasswordReset is a pending password reset. Only the hash of the token sent
+// to the user is stored.
+type PasswordReset struct {
+	TokenHash string
+	UserID    int
+	Sends     int
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(reset PasswordReset) error
+	// GetPasswordReset returns ErrInvalidToken if the reset is unknown,
+	// expired or already used
+	GetPasswordReset(tokenHash string) (PasswordReset, error)
+	// ConsumePasswordReset atomically marks the reset as used. It returns
+	// ErrInvalidToken if the reset is unknown, expired or already used.
+	ConsumePasswordReset(tokenHash string) (PasswordReset, error)
+	// GetUserPasswordReset returns the last reset sent to the user, used or
+	// not, or ErrInvalidToken when there is none
+	GetUserPasswordReset(userID int) (PasswordReset, error)
+	DeleteUserPasswordResets(userID int) error
+}
+
+type ForgotPasswordForm struct {
+	Identifier string `json:"identifier" validate:"required"`
+}
+
+func (f *ForgotPasswordForm) Validate() error {
+	return Validate(f)
+}
+
+type ResetPasswordForm struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (f *ResetPasswordForm) Validate() error {
+	return Validate(f)
+}
+
+// ForgotPassword sends a single use password reset token to the account
+// identified by username, email address or phone number. Tokens can be
+// resent after a wait that doubles with every token sent, and requests are
+// throttled per identifier and client IP. Unknown accounts and requests made
+// during the wait are ignored alike so callers can't tell whether an account
+// exists. The token is stored and sent in the background so known accounts
+// don't take longer to answer.
+func (s *UserService) ForgotPassword(identifier string, clientIP string) error {
+	if s.passwordResets == nil || s.notifier == nil {
+		return ErrPasswordResetDisabled
+	}
+	keys := s.passwordResetKeys(identifier, clientIP)
+	if err := s.checkLoginThrottle(keys); err != nil {
+		return err
+	}
+	if err := s.recordLoginFailure(keys); err != nil {
+		return err
+	}
+	user, err := s.findByIdentifier(identifier)
+	if err != nil {
+		// Look a pending reset up as for a known account
+		s.passwordResets.GetUserPasswordReset(0)
+		return nil
+	}
+
+	now := time.Now()
+	sends := 0
+	pending, err := s.passwordResets.GetUserPasswordReset(user.ID)
+	if err == nil {
+		interval := time.Duration(s.Config.PasswordResetResendInterval) * time.Second
+		resendAt := pending.CreatedAt.Add(resendWait(interval, pending.Sends))
+		if now.Before(resendAt) {
+			return nil
+		}
+		if now.Sub(pending.CreatedAt) < maxVerificationResendWait {
+			sends = pending.Sends
+		}
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	reset := PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Sends:     sends + 1,
+		ExpiresAt: now.Add(time.Duration(s.Config.PasswordResetExpTime) * time.Minute),
+		CreatedAt: now,
+	}
+	notification := Notification{
+		Kind:    NotificationPasswordReset,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use this token to reset your password within %d minutes: %s",
+			s.Config.PasswordResetExpTime, token),
+		Data: map[string]string{"token": token},
+	}
+	if s.Config.PasswordResetURL != "" {
+		link := s.Config.PasswordResetURL + token
+		notification.Body = fmt.Sprintf("Open this link to reset your password within %d minutes: %s",
+			s.Config.PasswordResetExpTime, link)
+		notification.Data["link"] = link
+	}
+	go func() {
+		if err := s.passwordResets.DeleteUserPasswordResets(user.ID); err != nil {
+			log.Println("Error deleting previous password resets. Error: ", err)
+			return
+		}
+		if err := s.passwordResets.CreatePasswordReset(reset); err != nil {
+			log.Println("Error creating password reset. Error: ", err)
+			return
+		}
+		if err := s.notifier.Notify(user, notification); err != nil {
+			log.Println("Error sending password reset notification. Error: ", err)
+		}
+	}()
+	return nil
+}
+
+// passwordResetKeys throttles password reset requests of an identifier and
+// of a client IP. Every request counts, whether or not the account exists.
+func (s *UserService) passwordResetKeys(identifier string, clientIP string) []loginAttemptKey {
+	keys := []loginAttemptKey{{"reset:" + strings.ToLower(identifier), s.loginThrottle.MaxAttempts, false}}
+	if clientIP != "" {
+		keys = append(keys, loginAttemptKey{"reset-ip:" + clientIP, s.loginThrottle.IPMaxAttempts, false})
+	}
+	return keys
+}
+
+// ResetPassword sets a new password using a token sent by ForgotPassword and
+// revokes every token issued to the user. The token is only used up once the
+// password is accepted by the policy.
+func (s *UserService) ResetPassword(token string, password string) error {
+	if s.passwordResets == nil {
+		return ErrPasswordResetDisabled
+	}
+	reset, err := s.passwordResets.GetPasswordReset(hashToken(token))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	user, err := s.repo.GetByID(reset.UserID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := s.checkPasswordPolicy(user, password); err != nil {
+		return err
+	}
+	if _, err := s.passwordResets.ConsumePasswordReset(reset.TokenHash); err != nil {
+		return ErrInvalidToken
+	}
+	if err := user.SetPasswordWith(s.hasher, password); err != nil {
+		return err
+	}
+	if _, err := s.repo.Update(user.ID, User{Password: user.Password}); err != nil {
+		return err
+	}
+	if err := s.passwordResets.DeleteUserPasswordResets(user.ID); err != nil {
+		return err
+	}
+	return s.LogoutAll(user.ID)
+}
+
+// findByIdentifier looks a user up by phone number when the identifier is in
+// E.164 format, by verified email address when it contains an @ and by
+// username otherwise. Usernames may look like phone numbers or email
+// addresses, so those fall back to the username when no account matches.
+// Every unknown identifier of a given form costs the same lookups.
+func (s *UserService) findByIdentifier(identifier string) (User, error) {
+	var user User
+	var err error
+	switch {
+	case strings.HasPrefix(identifier, "+"):
+		user, err = s.repo.GetByPhone(identifier)
+	case strings.Contains(identifier, "@"):
+		user, err = s.repo.GetByEmail(NormalizeEmail(identifier))
+	default:
+		return s.repo.GetByUsername(identifier)
+	}
+	if err == nil {
+		return user, nil
+	}
+	return s.repo.GetByUsername(identifier)
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are among the most used passwords in public breaches. They
+// and their leetspeak variants are guessed first by any attacker.
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "abc123", "111111", "123123",
+	"letmein", "welcome", "monkey", "dragon", "football", "baseball", "iloveyou",
+	"admin", "login", "master", "sunshine", "princess", "shadow", "superman",
+	"trustno1", "hello", "freedom", "whatever", "starwars", "passw0rd", "secret",
+	"qwertyuiop", "asdfghjkl", "zxcvbnm", "access", "mustang", "michael",
+	"charlie", "jordan", "liverpool", "chelsea", "computer", "internet",
+	"changeme", "default", "root", "user", "test", "guest", "summer", "winter",
+	"spring", "autumn", "january", "february", "march", "april", "june",
+	"july", "august", "september", "october", "november", "december",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+var leetSubstitutions = strings.NewReplacer(
+	"@", "a", "4", "a", "8", "b", "3", "e", "6", "g", "1", "i", "!", "i",
+	"0", "o", "$", "s", "5", "s", "7", "t", "+", "t", "2", "z",
+)
+
+// PasswordStrength estimates how hard the password is to guess on the same 0
+// to 4 scale as zxcvbn, where 0 falls to online guessing within minutes and 4
+// resists offline attacks. Words in userInputs, like the username, count as
+// known to the attacker.
+func PasswordStrength(password string, userInputs ...string) int {
+	guesses := math.Log10(2) * passwordEntropy(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// passwordEntropy returns the estimated entropy in bits. Characters that
+// repeat or continue a sequence or keyboard pattern add almost no entropy and
+// known words are worth the bits of their position in the word list.
+func passwordEntropy(password string, userInputs []string) float64 {
+	lowered := strings.ToLower(password)
+	if lowered == "" {
+		return 0
+	}
+
+	stripped := strings.TrimRightFunc(lowered, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	suffix := lowered[len(stripped):]
+	for _, candidate := range []string{stripped, leetSubstitutions.Replace(stripped)} {
+		if rank := wordRank(candidate, userInputs); rank > 0 {
+			bits := math.Log2(float64(rank)) + 1
+			if candidate != stripped {
+				bits++
+			}
+			return bits + suffixEntropy(suffix)
+		}
+	}
+	return patternEntropy(lowered, charsetSize(password))
+}
+
+// wordRank returns the 1 based position of the word in the list of known
+// words, or 0 when it is unknown
+func wordRank(word string, userInputs []string) int {
+	if word == "" {
+		return 0
+	}
+	for i, input := range userInputs {
+		if input != "" && word == strings.ToLower(input) {
+			return i + 1
+		}
+	}
+	for i, common := range commonPasswords {
+		if word == common {
+			return len(userInputs) + i + 1
+		}
+	}
+	return 0
+}
+
+// suffixEntropy is the entropy of the digits and symbols appended to a known
+// word, where a year is a single guess among a couple hundred
+func suffixEntropy(suffix string) float64 {
+	if len(suffix) == 4 && (strings.HasPrefix(suffix, "19") || strings.HasPrefix(suffix, "20")) {
+		if _, err := strconv.Atoi(suffix); err == nil {
+			return math.Log2(200)
+		}
+	}
+	return patternEntropy(suffix, charsetSize(suffix))
+}
+
+func patternEntropy(s string, charset int) float64 {
+	if s == "" {
+		return 0
+	}
+	perChar := math.Log2(float64(charset))
+	runes := []rune(s)
+	bits := perChar
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		switch {
+		case cur == prev:
+			bits += 1
+		case cur-prev == 1 || prev-cur == 1:
+			bits += 1.5
+		case keyboardAdjacent(prev, cur):
+			bits += 2
+		default:
+			bits += perChar
+		}
+	}
+	return bits
+}
+
+func keyboardAdjacent(a rune, b rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, a)
+		j := strings.IndexRune(row, b)
+		if i >= 0 && j >= 0 && (i-j == 1 || j-i == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// charsetSize is the size of the smallest set of character classes the
+// password is drawn from
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPasswordlessDisabled = errors.New("passwordless login is not available")
+	ErrInvalidLoginCode     = errors.New("invalid or expired login code")
+)
+
+const NotificationLoginCode = "login_code"
+
+// LoginCode is a pending passwordless login. Only the hash of the code sent
+// to the user is stored.
+type LoginCode struct {
+	UserID    int
+	CodeHash  string
+	Attempts  int
+	Sends     int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+type LoginCodeRepository interface {
+	// GetLoginCode returns ErrInvalidToken when the user has no pending code
+	GetLoginCode(userID int) (LoginCode, error)
+	SaveLoginCode(code LoginCode) error
+	RecordLoginCodeAttempt(userID int) error
+	// ConsumeLoginCode atomically deletes the code of the user if it matches,
+	// hasn't expired and was tried less than maxAttempts times. It returns
+	// ErrInvalidToken otherwise.
+	ConsumeLoginCode(userID int, codeHash string, maxAttempts int) error
+}
+
+type LoginCodeRequestForm struct {
+	Identifier string `json:"identifier" validate:"required"`
+}
+
+func (f *LoginCodeRequestForm) Validate() error {
+	return Validate(f)
+}
+
+type LoginCodeForm struct {
+	Identifier string `json:"identifier" validate:"required"`
+	Code       string `json:"code" validate:"required"`
+}
+
+func (f *LoginCodeForm) Validate() error {
+	return Validate(f)
+}
+
+// WithLoginCodeRepository enables passwordless login when
+// Config.PasswordlessLogin is set
+func WithLoginCodeRepository(r LoginCodeRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.loginCodes = r
+	}
+}
+
+// RequestLoginCode sends a one time login code to the verified phone number
+// or email address of the user. A phone number identifier gets the code by
+// SMS and an email address by notification, with a link when
+// Config.PasswordlessURL is set. For a username the verified email address
+// is preferred. Unknown users, users without a verified channel and requests
+// made before the previous code may be resent are ignored alike so callers
+// can't tell whether an account exists.
+func (s *UserService) RequestLoginCode(identifier string, clientIP string) error {
+	if !s.Config.PasswordlessLogin || s.loginCodes == nil {
+		return ErrPasswordlessDisabled
+	}
+	user, err := s.findByIdentifier(identifier)
+	username := identifier
+	if err == nil {
+		username = user.Username
+	}
+	if err := s.checkLoginThrottle(s.loginAttemptKeys(username, clientIP)); err != nil {
+		return err
+	}
+	if err != nil || !user.IsActive {
+		return nil
+	}
+	send := s.loginCodeSender(user, identifier)
+	if send == nil {
+		return nil
+	}
+
+	now := time.Now()
+	sends := 0
+	pending, err := s.loginCodes.GetLoginCode(user.ID)
+	if err == nil {
+		interval := time.Duration(s.Config.PasswordlessResendInterval) * time.Second
+		resendAt := pending.SentAt.Add(resendWait(interval, pending.Sends))
+		if now.Before(resendAt) {
+			return nil
+		}
+		if now.Sub(pending.SentAt) < maxVerificationResendWait {
+			sends = pending.Sends
+		}
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		return err
+	}
+	err = s.loginCodes.SaveLoginCode(LoginCode{
+		UserID:    user.ID,
+		CodeHash:  hashToken(code),
+		Sends:     sends + 1,
+		SentAt:    now,
+		ExpiresAt: now.Add(time.Duration(s.Config.PasswordlessCodeExpTime) * time.Minute),
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := send(code); err != nil {
+			log.Println("Error sending login code. Error: ", err)
+		}
+	}()
+	return nil
+}
+
+// loginCodeSender returns how to deliver a login code to the user, or nil
+// when the user has no verified channel matching the identifier
+func (s *UserService) loginCodeSender(user User, identifier string) func(code string) error {
+	isPhone := strings.HasPrefix(identifier, "+")
+	isEmail := strings.Contains(identifier, "@")
+	switch {
+	case !isPhone && user.Email != "" && user.EmailVerified && s.notifier != nil:
+		return func(code string) error {
+			return s.notifier.Notify(user, s.loginCodeNotification(user, code))
+		}
+	case !isEmail && user.PhoneVerified && s.sms != nil:
+		return func(code string) error {
+			message := fmt.Sprintf("Your %s login code is %s. It expires in %d minutes.",
+				s.Config.Issuer, code, s.Config.PasswordlessCodeExpTime)
+			return s.sms.SendSMS(user.Phone, message)
+		}
+	}
+	return nil
+}
+
+func (s *UserService) loginCodeNotification(user User, code string) Notification {
+	notification := Notification{
+		Kind:    NotificationLoginCode,
+		Subject: "Your login code",
+		Body: fmt.Sprintf("Use this code to log in within %d minutes: %s",
+			s.Config.PasswordlessCodeExpTime, code),
+		Data: map[string]string{"code": code},
+	}
+	if s.Config.PasswordlessURL != "" {
+		query := url.Values{}
+		query.Set("identifier", user.Email)
+		query.Set("code", code)
+		link := s.Config.PasswordlessURL + query.Encode()
+		notification.Body = fmt.Sprintf("Open this link to log in within %d minutes: %s",
+			s.Config.PasswordlessCodeExpTime, link)
+		notification.Data["link"] = link
+	}
+	return notification
+}
+
+// LoginWithCode redeems a code sent by RequestLoginCode. Wrong codes are
+// throttled like failed logins and a code can only be used once, within
+// Config.PasswordlessMaxAttempts tries.
+func (s *UserService) LoginWithCode(identifier string, code string, clientIP string) (User, error) {
+	if !s.Config.PasswordlessLogin || s.loginCodes == nil {
+		return User{}, ErrPasswordlessDisabled
+	}
+	user, err := s.findByIdentifier(identifier)
+	username := identifier
+	if err == nil {
+		username = user.Username
+	}
+	keys := s.loginAttemptKeys(username, clientIP)
+	if err := s.startLoginAttempt(keys); err != nil {
+		return User{}, err
+	}
+
+	if err == nil {
+		err = s.checkLoginCode(user.ID, code)
+	}
+	if err != nil {
+		if recordErr := s.recordLoginFailure(keys); recordErr != nil {
+			log.Println("Error recording failed login. Error: ", recordErr)
+		}
+		return User{}, ErrInvalidLoginCode
+	}
+	if err := s.resetLoginFailures(user.Username); err != nil {
+		log.Println("Error resetting failed logins. Error: ", err)
+	}
+	if err := s.checkCanLogin(user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *UserService) checkLoginCode(userID int, code string) error {
+	pending, err := s.loginCodes.GetLoginCode(userID)
+	if err != nil {
+		return err
+	}
+	codeHash := hashToken(strings.TrimSpace(code))
+	if subtle.ConstantTimeCompare([]byte(pending.CodeHash), []byte(codeHash)) != 1 {
+		if err := s.loginCodes.RecordLoginCodeAttempt(userID); err != nil {
+			log.Println("Error recording failed login code. Error: ", err)
+		}
+		return ErrInvalidToken
+	}
+	return s.loginCodes.ConsumeLoginCode(userID, codeHash, s.Config.PasswordlessMaxAttempts)
+}
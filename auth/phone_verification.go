@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrPhoneVerificationDisabled = errors.New("phone verification is not available")
+	ErrInvalidVerificationCode   = errors.New("invalid or expired verification code")
+	ErrPhoneNotVerified          = errors.New("phone number is not verified")
+)
+
+const verificationCodeDigits = 6
+
+// maxVerificationResendWait caps the wait between two codes sent to a user
+const maxVerificationResendWait = time.Hour
+
+// PhoneVerification is the pending verification of the phone number of a
+// user. Only the hash of the code sent by SMS is stored.
+type PhoneVerification struct {
+	UserID    int
+	Phone     string
+	CodeHash  string
+	Attempts  int
+	Sends     int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+type PhoneVerificationRepository interface {
+	// GetPhoneVerification returns ErrInvalidVerificationCode when the user
+	// has no pending verification
+	GetPhoneVerification(userID int) (PhoneVerification, error)
+	SavePhoneVerification(v PhoneVerification) error
+	// RecordPhoneVerificationAttempt atomically counts an attempt at the
+	// pending code and returns the number of attempts including this one. It
+	// returns ErrInvalidVerificationCode when the user has no pending
+	// verification.
+	RecordPhoneVerificationAttempt(userID int) (int, error)
+	DeletePhoneVerification(userID int) error
+}
+
+type PhoneVerificationRequestForm struct {
+	Phone string `json:"phone" validate:"required,e164"`
+}
+
+func (f *PhoneVerificationRequestForm) Validate() error {
+	return Validate(f)
+}
+
+type PhoneVerificationForm struct {
+	Phone string `json:"phone" validate:"required,e164"`
+	Code  string `json:"code" validate:"required"`
+}
+
+func (f *PhoneVerificationForm) Validate() error {
+	return Validate(f)
+}
+
+// WithPhoneVerificationRepository enables phone number verification, which
+// also needs an SMSSender
+func WithPhoneVerificationRepository(r PhoneVerificationRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.phoneVerifications = r
+	}
+}
+
+// WithSMSSender sets how text messages are delivered to users
+func WithSMSSender(sender SMSSender) UserServiceOption {
+	return func(s *UserService) {
+		s.sms = sender
+	}
+}
+
+// SendPhoneVerification texts a verification code to the phone number.
+// Codes can be resent after a wait that doubles with every code sent.
+// Unknown and already verified numbers and requests made during the wait
+// are ignored alike so callers can't tell which numbers are registered.
+func (s *UserService) SendPhoneVerification(phone string) error {
+	if s.phoneVerifications == nil || s.sms == nil {
+		return ErrPhoneVerificationDisabled
+	}
+	user, err := s.repo.GetByPhone(phone)
+	if err != nil || user.PhoneVerified {
+		return nil
+	}
+
+	now := time.Now()
+	sends := 0
+	pending, err := s.phoneVerifications.GetPhoneVerification(user.ID)
+	if err == nil && pending.Phone == phone {
+		interval := time.Duration(s.Config.PhoneVerificationResendInterval) * time.Second
+		resendAt := pending.SentAt.Add(resendWait(interval, pending.Sends))
+		if now.Before(resendAt) {
+			return nil
+		}
+		if now.Sub(pending.SentAt) < maxVerificationResendWait {
+			sends = pending.Sends
+		}
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		return err
+	}
+	err = s.phoneVerifications.SavePhoneVerification(PhoneVerification{
+		UserID:    user.ID,
+		Phone:     phone,
+		CodeHash:  hashToken(code),
+		Sends:     sends + 1,
+		SentAt:    now,
+		ExpiresAt: now.Add(time.Duration(s.Config.PhoneVerificationExpTime) * time.Minute),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your %s verification code is %s. It expires in %d minutes.",
+		s.Config.Issuer, code, s.Config.PhoneVerificationExpTime)
+	go func() {
+		if err := s.sms.SendSMS(phone, message); err != nil {
+			log.Println("Error sending phone verification code. Error: ", err)
+		}
+	}()
+	return nil
+}
+
+// VerifyPhone marks the phone number as verified when the code matches the
+// last one sent to it. A code can only be tried a limited number of times
+// before a new one has to be requested. The attempt is counted before the
+// code is compared so parallel guesses can't exceed the limit.
+func (s *UserService) VerifyPhone(phone string, code string) error {
+	if s.phoneVerifications == nil {
+		return ErrPhoneVerificationDisabled
+	}
+	user, err := s.repo.GetByPhone(phone)
+	if err != nil {
+		return ErrInvalidVerificationCode
+	}
+	pending, err := s.phoneVerifications.GetPhoneVerification(user.ID)
+	if err != nil {
+		return ErrInvalidVerificationCode
+	}
+	if pending.Phone != phone || time.Now().After(pending.ExpiresAt) || pending.Attempts >= s.Config.PhoneVerificationMaxAttempts {
+		return ErrInvalidVerificationCode
+	}
+	attempts, err := s.phoneVerifications.RecordPhoneVerificationAttempt(user.ID)
+	if err != nil {
+		return err
+	}
+	if attempts > s.Config.PhoneVerificationMaxAttempts {
+		return ErrInvalidVerificationCode
+	}
+	if subtle.ConstantTimeCompare([]byte(pending.CodeHash), []byte(hashToken(code))) != 1 {
+		return ErrInvalidVerificationCode
+	}
+
+	if err := s.repo.SetPhoneVerified(user.ID, true); err != nil {
+		return err
+	}
+	return s.phoneVerifications.DeletePhoneVerification(user.ID)
+}
+
+// resendWait is how long to wait before sending another code after the
+// given number of codes were sent, doubling with every code
+func resendWait(interval time.Duration, sends int) time.Duration {
+	return exponential(interval, sends-1, maxVerificationResendWait)
+}
+
+func newVerificationCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < verificationCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import "errors"
+
+var ErrForbidden = errors.New("you are not allowed to perform this action")
+
+type Permission string
+
+const (
+	PermissionReadUsers     Permission = "users:read"
+	PermissionCreateUsers   Permission = "users:create"
+	PermissionUpdateUsers   Permission = "users:update"
+	PermissionDeleteUsers   Permission = "users:delete"
+	PermissionReadSelf      Permission = "users:read:self"
+	PermissionUpdateSelf    Permission = "users:update:self"
+	PermissionRotateKeys    Permission = "keys:rotate"
+	PermissionManageClients Permission = "clients:manage"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+type Role struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type Roles []Role
+
+// DefaultRoles are the roles available when no RoleRepository is configured
+// and the ones seeded into a new database
+var DefaultRoles = Roles{
+	{
+		Name: RoleAdmin,
+		Permissions: []Permission{
+			PermissionReadUsers,
+			PermissionCreateUsers,
+			PermissionUpdateUsers,
+			PermissionDeleteUsers,
+			PermissionReadSelf,
+			PermissionUpdateSelf,
+			PermissionRotateKeys,
+			PermissionManageClients,
+		},
+	},
+	{
+		Name:        RoleUser,
+		Permissions: []Permission{PermissionReadSelf, PermissionUpdateSelf},
+	},
+}
+
+type RoleRepository interface {
+	GetRole(name string) (Role, error)
+	GetRoles() (Roles, error)
+	SaveRole(role Role) error
+}
+
+func (r Role) Has(p Permission) bool {
+	for _, permission := range r.Permissions {
+		if permission == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Roles) Get(name string) (Role, bool) {
+	for _, role := range r {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return Role{}, false
+}
+
+// HasRole reports whether the caller has one of the roles. Admins have every
+// role.
+func (s *UserService) HasRole(claim JWTClaim, roles ...string) bool {
+	if claim.IsAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if claim.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the role of the caller grants the
+// permission. Admins have every permission.
+func (s *UserService) HasPermission(claim JWTClaim, p Permission) bool {
+	if claim.IsAdmin {
+		return true
+	}
+	role, err := s.getRole(claim.Role)
+	if err != nil {
+		return false
+	}
+	return role.Has(p)
+}
+
+// getRole loads a role, users created before roles existed have the user role
+func (s *UserService) getRole(name string) (Role, error) {
+	if name == "" {
+		name = RoleUser
+	}
+	if s.roles != nil {
+		return s.roles.GetRole(name)
+	}
+	role, ok := DefaultRoles.Get(name)
+	if !ok {
+		return Role{}, ErrForbidden
+	}
+	return role, nil
+}
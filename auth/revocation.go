@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore is a RevocationStore kept in process memory. It is
+// only suitable when a single instance of the service is running.
+type MemoryRevocationStore struct {
+	mu        sync.RWMutex
+	tokens    map[string]time.Time
+	users     map[int]time.Time
+	retention time.Duration
+}
+
+// NewMemoryRevocationStore creates an in-memory store. Per user revocations
+// are forgotten after retention, which should be the lifetime of the longest
+// lived token.
+func NewMemoryRevocationStore(retention time.Duration) *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:    map[string]time.Time{},
+		users:     map[int]time.Time{},
+		retention: retention,
+	}
+}
+
+func (m *MemoryRevocationStore) RevokeToken(id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+	m.tokens[id] = expiresAt
+	return nil
+}
+
+func (m *MemoryRevocationStore) IsTokenRevoked(id string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.tokens[id]
+	return ok, nil
+}
+
+func (m *MemoryRevocationStore) RevokeUserTokens(userID int, issuedBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+	m.users[userID] = issuedBefore
+	return nil
+}
+
+func (m *MemoryRevocationStore) UserTokensRevokedAt(userID int) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.users[userID], nil
+}
+
+func (m *MemoryRevocationStore) prune(now time.Time) {
+	for id, expiresAt := range m.tokens {
+		if now.After(expiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+	for id, revokedAt := range m.users {
+		if now.Sub(revokedAt) > m.retention {
+			delete(m.users, id)
+		}
+	}
+}
@@ -1,163 +1,813 @@
-package auth
-
-import (
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/mohaali482/goAuth/config"
-)
-
-type UserService struct {
-	repo   Repository
-	Config *config.Config
-}
-
-func NewUserService(r Repository, c *config.Config) *UserService {
-	return &UserService{
-		repo:   r,
-		Config: c,
-	}
-}
-
-func (s *UserService) Create(u User) (User, error) {
-	if err := u.Validate(); err != nil {
-		return User{}, err
-	}
-	if _, err := s.repo.GetByUsername(u.Username); err == nil {
-		return User{}, ErrUsernameExists
-	}
-	if _, err := s.repo.GetByPhone(u.Phone); err == nil {
-		return User{}, ErrPhoneExists
-	}
-
-	u.SetPassword(u.Password)
-	return s.repo.Create(u)
-}
-
-func (s *UserService) GetAll() (Users, error) {
-	return s.repo.GetAll()
-}
-
-func (s *UserService) GetByID(id int) (User, error) {
-	return s.repo.GetByID(id)
-}
-
-func (s *UserService) GetByUsername(username string) (User, error) {
-	return s.repo.GetByUsername(username)
-}
-
-func (s *UserService) GetByPhone(phone string) (User, error) {
-	return s.repo.GetByUsername(phone)
-}
-
-func (s *UserService) Update(id int, u User) (User, error) {
-	if u.Username != "" {
-		user, err := s.repo.GetByUsername(u.Username)
-		if err == nil {
-			if user.ID != id {
-				return User{}, ErrUsernameExists
-			}
-		}
-	}
-	if u.Phone != "" {
-		user, err := s.repo.GetByPhone(u.Phone)
-		if err == nil {
-			if user.ID != id {
-				return User{}, ErrPhoneExists
-			}
-		}
-	}
-
-	if u.Password != "" {
-		u.SetPassword(u.Password)
-	}
-
-	_, err := s.repo.Update(id, u)
-	if err != nil {
-		return User{}, nil
-	}
-
-	return s.repo.GetByID(id)
-}
-
-func (s *UserService) Delete(id int) error {
-	return s.repo.Delete(id)
-}
-
-func (s *UserService) Login(username string, password string) (User, error) {
-	user, err := s.repo.GetByUsername(username)
-	if err != nil {
-		return User{}, err
-	}
-
-	err = user.CheckPassword(password)
-	if err != nil {
-		return User{}, err
-	}
-
-	return user, nil
-}
-
-func (s *UserService) GenerateJWT(user User) (map[string]string, error) {
-	accessTokenExpirationTime := time.Now().Add(time.Duration(s.Config.AccessExpTime) * time.Minute)
-	refreshTokenExpirationTime := time.Now().Add(time.Duration(s.Config.RefreshExpTime) * time.Minute)
-	jwtClaim := JWTClaim{
-		ID:       user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-		Token:    Access,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(accessTokenExpirationTime),
-		},
-	}
-	refreshJwtClaim := JWTClaim{
-		ID:       user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-		Token:    Refresh,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(refreshTokenExpirationTime),
-		},
-	}
-
-	t, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaim).SignedString([]byte(s.Config.Secret))
-	if err != nil {
-		return nil, err
-	}
-
-	rt, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshJwtClaim).SignedString([]byte(s.Config.Secret))
-	if err != nil {
-		return nil, err
-	}
-
-	return map[string]string{
-		"access":  t,
-		"refresh": rt,
-	}, nil
-}
-
-func (s *UserService) ValidateJWT(token string) (JWTClaim, error) {
-	var jwtClaim JWTClaim
-	_, err := jwt.ParseWithClaims(token, &jwtClaim, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.Config.Secret), nil
-	})
-	if err != nil {
-		return JWTClaim{}, err
-	}
-	return jwtClaim, nil
-}
-
-func (s *UserService) RefreshToken(token string) (map[string]string, error) {
-	jwtClaim, err := s.ValidateJWT(token)
-	if err != nil {
-		return nil, err
-	}
-	if jwtClaim.Token != Refresh {
-		return nil, ErrInvalidToken
-	}
-	user, err := s.repo.GetByID(jwtClaim.ID)
-	if err != nil {
-		return nil, err
-	}
-	return s.GenerateJWT(user)
-}
+package auth
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mohaali482/goAuth/config"
+)
+
+type UserService struct {
+	repo               Repository
+	refreshTokens      RefreshTokenRepository
+	revocations        RevocationStore
+	roles              RoleRepository
+	passwordResets     PasswordResetRepository
+	notifier           Notifier
+	hasher             PasswordHasher
+	passwordPolicy     PasswordPolicy
+	breached           BreachedPasswordChecker
+	loginAttempts      LoginAttemptStore
+	loginThrottle      LoginThrottle
+	statusCache        *UserStatusCache
+	mfa                MFARepository
+	phoneVerifications PhoneVerificationRepository
+	sms                SMSSender
+	emailVerifications EmailVerificationRepository
+	loginCodes         LoginCodeRepository
+	webAuthn           WebAuthnRepository
+	sessions           SessionRepository
+	opaqueTokens       OpaqueTokenRepository
+	oauth              OAuthRepository
+	signingKeys        *signingKeyStore
+	dummyHash          string
+	keys               *KeyRing
+	Config             *config.Config
+}
+
+type UserServiceOption func(s *UserService)
+
+func NewUserService(r Repository, c *config.Config, options ...UserServiceOption) *UserService {
+	s := &UserService{
+		repo:           r,
+		hasher:         DefaultPasswordHasher,
+		passwordPolicy: NewPasswordPolicy(c),
+		loginThrottle:  NewLoginThrottle(c),
+		Config:         c,
+	}
+	key, _ := NewHMACKey(c.KeyID, jwt.SigningMethodHS256, []byte(c.Secret))
+	s.keys = NewKeyRing(key, s.keyRetention())
+
+	for _, o := range options {
+		o(s)
+	}
+	if s.signingKeys != nil {
+		s.signingKeys.base = s.keys.Current()
+	}
+	s.dummyHash = s.newDummyHash()
+	return s
+}
+
+// newDummyHash hashes a random password with the configured hasher. Logins
+// of unknown users are checked against it so they take as long as the ones
+// of existing users.
+func (s *UserService) newDummyHash() string {
+	password, err := randomHex(16)
+	if err != nil {
+		return ""
+	}
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// WithSigningKey sets the key used to sign and verify tokens, replacing the
+// default HS256 key derived from Config.Secret
+func WithSigningKey(k SigningKey) UserServiceOption {
+	return func(s *UserService) {
+		s.keys = NewKeyRing(k, s.keyRetention())
+	}
+}
+
+// WithKeyRing sets the key ring used to sign and verify tokens
+func WithKeyRing(r *KeyRing) UserServiceOption {
+	return func(s *UserService) {
+		s.keys = r
+	}
+}
+
+// WithRefreshTokenRepository enables refresh token rotation. Every refresh
+// token can then only be used once and reusing one revokes its whole family.
+func WithRefreshTokenRepository(r RefreshTokenRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.refreshTokens = r
+	}
+}
+
+// WithRevocationStore makes ValidateJWT reject tokens revoked by Logout and
+// LogoutAll
+func WithRevocationStore(r RevocationStore) UserServiceOption {
+	return func(s *UserService) {
+		s.revocations = r
+	}
+}
+
+// WithRoleRepository sets where role permissions are loaded from instead of
+// DefaultRoles
+func WithRoleRepository(r RoleRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.roles = r
+	}
+}
+
+// WithPasswordResetRepository enables the forgot password flow, which also
+// needs a Notifier
+func WithPasswordResetRepository(r PasswordResetRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.passwordResets = r
+	}
+}
+
+// WithNotifier sets how notifications are delivered to users
+func WithNotifier(n Notifier) UserServiceOption {
+	return func(s *UserService) {
+		s.notifier = n
+	}
+}
+
+// WithPasswordHasher sets how new passwords are hashed. Users whose hash was
+// produced by another hasher are rehashed the next time they log in.
+func WithPasswordHasher(h PasswordHasher) UserServiceOption {
+	return func(s *UserService) {
+		s.hasher = h
+	}
+}
+
+// WithPasswordPolicy replaces the policy built from the configuration
+func WithPasswordPolicy(p PasswordPolicy) UserServiceOption {
+	return func(s *UserService) {
+		s.passwordPolicy = p
+	}
+}
+
+// WithBreachedPasswordChecker rejects new passwords found by the checker on
+// top of the password policy, whichever of the two options comes first
+func WithBreachedPasswordChecker(c BreachedPasswordChecker) UserServiceOption {
+	return func(s *UserService) {
+		s.breached = c
+	}
+}
+
+// keyRetention is how long a retired key keeps verifying tokens, which is the
+// lifetime of the longest lived token signed with it
+func (s *UserService) keyRetention() time.Duration {
+	return time.Duration(s.Config.RefreshExpTime) * time.Minute
+}
+
+func (s *UserService) Create(u User) (User, error) {
+	if err := s.checkNewUser(&u); err != nil {
+		return User{}, err
+	}
+	return s.insertUser(u)
+}
+
+// checkNewUser normalizes and validates the details of a new user without
+// looking at existing accounts
+func (s *UserService) checkNewUser(u *User) error {
+	u.Email = NormalizeEmail(u.Email)
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.Email == "" && s.Config.RequireEmail {
+		return ErrEmailRequired
+	}
+	return s.checkPasswordRules(*u, u.Password)
+}
+
+// insertUser stores a new user checked by checkNewUser unless its username,
+// phone number or email address is taken
+func (s *UserService) insertUser(u User) (User, error) {
+	if _, err := s.repo.GetByUsername(u.Username); err == nil {
+		return User{}, ErrUsernameExists
+	}
+	if _, err := s.repo.GetByPhone(u.Phone); err == nil {
+		return User{}, ErrPhoneExists
+	}
+	if u.Email != "" {
+		if _, err := s.repo.GetByEmail(u.Email); err == nil {
+			return User{}, ErrEmailExists
+		}
+	}
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+	u.IsActive = true
+
+	if err := u.SetPasswordWith(s.hasher, u.Password); err != nil {
+		return User{}, err
+	}
+	return s.repo.Create(u)
+}
+
+func (s *UserService) GetAll() (Users, error) {
+	return s.repo.GetAll()
+}
+
+func (s *UserService) GetByID(id int) (User, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *UserService) GetByUsername(username string) (User, error) {
+	return s.repo.GetByUsername(username)
+}
+
+func (s *UserService) GetByPhone(phone string) (User, error) {
+	return s.repo.GetByUsername(phone)
+}
+
+func (s *UserService) GetByEmail(email string) (User, error) {
+	return s.repo.GetByEmail(NormalizeEmail(email))
+}
+
+func (s *UserService) Update(id int, u User) (User, error) {
+	if u.Username != "" {
+		user, err := s.repo.GetByUsername(u.Username)
+		if err == nil {
+			if user.ID != id {
+				return User{}, ErrUsernameExists
+			}
+		}
+	}
+	if u.Phone != "" {
+		user, err := s.repo.GetByPhone(u.Phone)
+		if err == nil {
+			if user.ID != id {
+				return User{}, ErrPhoneExists
+			}
+		}
+	}
+	u.Email = NormalizeEmail(u.Email)
+	if u.Email != "" {
+		user, err := s.repo.GetByEmail(u.Email)
+		if err == nil {
+			if user.ID != id {
+				return User{}, ErrEmailExists
+			}
+		}
+	}
+
+	if u.Password != "" {
+		user, err := s.repo.GetByID(id)
+		if err != nil {
+			return User{}, err
+		}
+		if u.Username != "" {
+			user.Username = u.Username
+		}
+		if u.Phone != "" {
+			user.Phone = u.Phone
+		}
+		if u.Email != "" {
+			user.Email = u.Email
+		}
+		if err := s.checkPasswordRules(user, u.Password); err != nil {
+			return User{}, err
+		}
+		if err := u.SetPasswordWith(s.hasher, u.Password); err != nil {
+			return User{}, err
+		}
+	}
+
+	current, err := s.repo.GetByID(id)
+	if err != nil {
+		return User{}, err
+	}
+	_, err = s.repo.Update(id, u)
+	if err != nil {
+		return User{}, err
+	}
+	if u.Phone != "" && u.Phone != current.Phone && current.PhoneVerified {
+		if err := s.repo.SetPhoneVerified(id, false); err != nil {
+			return User{}, err
+		}
+	}
+	if u.Email != "" && u.Email != current.Email && current.EmailVerified {
+		if err := s.repo.SetEmailVerified(id, false); err != nil {
+			return User{}, err
+		}
+	}
+
+	return s.repo.GetByID(id)
+}
+
+// UpdateSelf updates the profile of the calling user. Users can't change
+// their own role, admin flag or account status, and passwords are changed
+// through ChangePassword. Changing the email address requires the current
+// password and is notified to the previous address when it was verified.
+func (s *UserService) UpdateSelf(id int, u User, currentPassword string) (User, error) {
+	u.Password = ""
+	u.Role = ""
+	u.IsAdmin = false
+	u.IsActive = false
+	current, err := s.repo.GetByID(id)
+	if err != nil {
+		return User{}, err
+	}
+	email := NormalizeEmail(u.Email)
+	emailChanged := email != "" && email != current.Email
+	if emailChanged {
+		if err := s.checkCurrentPassword(current, currentPassword); err != nil {
+			return User{}, err
+		}
+	}
+	user, err := s.Update(id, u)
+	if err != nil {
+		return User{}, err
+	}
+	if emailChanged && current.Email != "" && current.EmailVerified {
+		s.notifyEmailChanged(current, email)
+	}
+	return user, nil
+}
+
+// checkCurrentPassword confirms the identity of a logged in user before a
+// sensitive change. Wrong passwords count as failed logins of the user so
+// the check can't be used to guess the password faster than logging in.
+func (s *UserService) checkCurrentPassword(user User, password string) error {
+	keys := s.loginAttemptKeys(user.Username, "")
+	if err := s.startLoginAttempt(keys); err != nil {
+		return err
+	}
+	if err := user.CheckPassword(password); err != nil {
+		return ErrWrongCredentials
+	}
+	if err := s.resetLoginFailures(user.Username); err != nil {
+		log.Println("Error resetting failed logins. Error: ", err)
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of the calling user after verifying
+// the current one, which is throttled like logins. Every refresh token of the
+// user except the ones of the calling session is revoked.
+func (s *UserService) ChangePassword(claim JWTClaim, currentPassword string, newPassword string) error {
+	user, err := s.repo.GetByID(claim.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCurrentPassword(user, currentPassword); err != nil {
+		return err
+	}
+	if err := s.checkPasswordPolicy(user, newPassword); err != nil {
+		if policyErr, ok := err.(*PasswordPolicyError); ok {
+			policyErr.Field = "new_password"
+		}
+		return err
+	}
+	if err := user.SetPasswordWith(s.hasher, newPassword); err != nil {
+		return err
+	}
+	if _, err := s.repo.Update(user.ID, User{Password: user.Password}); err != nil {
+		return err
+	}
+	return s.endSessions(user.ID, claim.Family)
+}
+
+// endSessions revokes the sessions, opaque tokens and refresh tokens of the
+// user except the ones of the exceptFamily token family
+func (s *UserService) endSessions(userID int, exceptFamily string) error {
+	if err := s.revokeSessions(userID, exceptFamily); err != nil {
+		return err
+	}
+	if err := s.deleteUserOpaqueTokens(userID, exceptFamily); err != nil {
+		return err
+	}
+	if s.refreshTokens != nil {
+		return s.refreshTokens.RevokeUserRefreshTokens(userID, exceptFamily)
+	}
+	return nil
+}
+
+// checkPasswordPolicy validates a new password for the user, which must also
+// differ from the current one
+func (s *UserService) checkPasswordPolicy(user User, password string) error {
+	if err := s.checkPasswordRules(user, password); err != nil {
+		return err
+	}
+	if user.Password != "" && user.CheckPassword(password) == nil {
+		return ErrSamePassword
+	}
+	return nil
+}
+
+// checkPasswordRules checks a password against the policy and the breached
+// password checker set with WithBreachedPasswordChecker
+func (s *UserService) checkPasswordRules(user User, password string) error {
+	policy := s.passwordPolicy
+	if s.breached != nil {
+		policy.Breached = s.breached
+	}
+	return policy.Check(user, password)
+}
+
+// Delete deletes the account of the user and revokes all of its tokens
+func (s *UserService) Delete(id int) error {
+	if err := s.LogoutAll(id); err != nil {
+		return err
+	}
+	s.invalidateStatus(id)
+	return s.repo.Delete(id)
+}
+
+// DeleteSelf deletes the account of the calling user and revokes all of its
+// tokens
+func (s *UserService) DeleteSelf(id int) error {
+	return s.Delete(id)
+}
+
+// Login checks the credentials of the user identified by username, email
+// address or phone number. With a LoginAttemptStore failed attempts are
+// counted per username and client IP and further attempts are
+// throttled with a *LoginThrottledError. Unknown identifiers and wrong
+// passwords both return ErrWrongCredentials after the same amount of work.
+// Inactive users get ErrUserInactive once their password is verified, and
+// so do users with an unverified phone number with ErrPhoneNotVerified when
+// phone verification is required.
+func (s *UserService) Login(identifier string, password string, clientIP string) (User, error) {
+	user, err := s.findByIdentifier(identifier)
+	username := identifier
+	if err == nil {
+		username = user.Username
+	}
+	keys := s.loginAttemptKeys(username, clientIP)
+	if err := s.startLoginAttempt(keys); err != nil {
+		return User{}, err
+	}
+
+	if err != nil {
+		VerifyPassword(s.dummyHash, password)
+	} else {
+		err = user.CheckPassword(password)
+	}
+	if err != nil {
+		if recordErr := s.recordLoginFailure(keys); recordErr != nil {
+			log.Println("Error recording failed login. Error: ", recordErr)
+		}
+		return User{}, ErrWrongCredentials
+	}
+	if err := s.resetLoginFailures(username); err != nil {
+		log.Println("Error resetting failed logins. Error: ", err)
+	}
+	if err := s.checkCanLogin(user); err != nil {
+		return User{}, err
+	}
+	s.rehashPassword(&user, password)
+
+	return user, nil
+}
+
+// checkCanLogin returns ErrUserInactive if the user is deactivated and
+// ErrPhoneNotVerified if phone verification is required and the user hasn't
+// verified their number. Every way of logging in checks it once the user has
+// proven who they are.
+func (s *UserService) checkCanLogin(user User) error {
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	if s.Config.RequirePhoneVerification && !user.PhoneVerified {
+		return ErrPhoneNotVerified
+	}
+	return nil
+}
+
+// rehashPassword upgrades the stored hash of the user when it was produced
+// with an outdated algorithm or cost. Failures are logged and don't prevent
+// the login.
+func (s *UserService) rehashPassword(user *User, password string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	if err := user.SetPasswordWith(s.hasher, password); err != nil {
+		log.Println("Error rehashing password. Error: ", err)
+		return
+	}
+	if _, err := s.repo.Update(user.ID, User{Password: user.Password}); err != nil {
+		log.Println("Error saving rehashed password. Error: ", err)
+	}
+}
+
+// GenerateJWT issues a new access and refresh token pair starting a new
+// refresh token family, and a new session for the client logging in
+func (s *UserService) GenerateJWT(user User, userAgent string, clientIP string) (map[string]string, error) {
+	return s.issueTokens(user, "", "", userAgent, clientIP)
+}
+
+// issueTokens starts a new refresh token family and session. Tokens issued
+// to an OAuth client carry its id and can only be refreshed by it.
+func (s *UserService) issueTokens(user User, clientID string, scope string, userAgent string, clientIP string) (map[string]string, error) {
+	family, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.generateTokens(user, family, clientID, scope)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(s.Config.RefreshExpTime) * time.Minute)
+	if err := s.startSession(user.ID, family, clientID, userAgent, clientIP, expiresAt); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *UserService) generateTokens(user User, family string, clientID string, scope string) (map[string]string, error) {
+	jwtClaim, err := s.newClaim(user, Access, family, time.Duration(s.Config.AccessExpTime)*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	refreshJwtClaim, err := s.newClaim(user, Refresh, family, time.Duration(s.Config.RefreshExpTime)*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	jwtClaim.ClientID = clientID
+	refreshJwtClaim.ClientID = clientID
+	jwtClaim.Scope = scope
+	refreshJwtClaim.Scope = scope
+
+	t, err := s.issueAccessToken(jwtClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	rt, err := s.sign(refreshJwtClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.refreshTokens != nil {
+		err = s.refreshTokens.CreateRefreshToken(RefreshToken{
+			ID:        refreshJwtClaim.RegisteredClaims.ID,
+			Family:    family,
+			UserID:    user.ID,
+			ExpiresAt: refreshJwtClaim.ExpiresAt.Time,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]string{
+		"access":  t,
+		"refresh": rt,
+	}, nil
+}
+
+func (s *UserService) newClaim(user User, tokenType string, family string, lifetime time.Duration) (JWTClaim, error) {
+	id, err := newID()
+	if err != nil {
+		return JWTClaim{}, err
+	}
+	now := time.Now()
+	return JWTClaim{
+		ID:       user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		IsAdmin:  user.IsAdmin,
+		Token:    tokenType,
+		Family:   family,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    s.Config.Issuer,
+			Subject:   strconv.Itoa(user.ID),
+			Audience:  s.Config.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		},
+	}, nil
+}
+
+// ValidateJWT checks the signature, registered claims and revocation status
+// of the token and that it is of the expected type (Access or Refresh). Opaque
+// access tokens are looked up in the OpaqueTokenRepository instead.
+func (s *UserService) ValidateJWT(token string, tokenType string) (JWTClaim, error) {
+	jwtClaim, err := s.parseToken(token)
+	if err != nil {
+		return JWTClaim{}, err
+	}
+	if jwtClaim.Token != tokenType {
+		return JWTClaim{}, ErrInvalidToken
+	}
+	if err := s.checkRevoked(jwtClaim); err != nil {
+		return JWTClaim{}, err
+	}
+	if err := s.checkSession(jwtClaim); err != nil {
+		return JWTClaim{}, err
+	}
+	return jwtClaim, nil
+}
+
+func (s *UserService) parseJWT(token string) (JWTClaim, error) {
+	var jwtClaim JWTClaim
+	options := []jwt.ParserOption{jwt.WithIssuedAt()}
+	if s.Config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(s.Config.Issuer))
+	}
+	_, err := jwt.ParseWithClaims(token, &jwtClaim, s.verificationKey, options...)
+	if err != nil {
+		return JWTClaim{}, err
+	}
+	if jwtClaim.ExpiresAt == nil || jwtClaim.Subject != strconv.Itoa(jwtClaim.ID) || !s.hasAudience(jwtClaim) {
+		return JWTClaim{}, ErrInvalidToken
+	}
+	return jwtClaim, nil
+}
+
+// hasAudience reports whether the token is intended for at least one of the
+// configured audiences
+func (s *UserService) hasAudience(claim JWTClaim) bool {
+	if len(s.Config.Audience) == 0 {
+		return true
+	}
+	for _, expected := range s.Config.Audience {
+		for _, aud := range claim.Audience {
+			if aud == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *UserService) checkRevoked(claim JWTClaim) error {
+	if s.revocations == nil {
+		return nil
+	}
+	revoked, err := s.revocations.IsTokenRevoked(claim.RegisteredClaims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrInvalidToken
+	}
+	revokedAt, err := s.revocations.UserTokensRevokedAt(claim.ID)
+	if err != nil {
+		return err
+	}
+	if !revokedAt.IsZero() && (claim.IssuedAt == nil || !claim.IssuedAt.After(revokedAt)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Logout revokes the given tokens, usually the access and refresh token of
+// the current session. Tokens that are already invalid are ignored.
+func (s *UserService) Logout(tokens ...string) error {
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if s.opaqueTokens != nil && isOpaqueToken(token) {
+			if err := s.opaqueTokens.DeleteOpaqueToken(hashToken(token)); err != nil {
+				return err
+			}
+			continue
+		}
+		jwtClaim, err := s.parseJWT(token)
+		if err != nil {
+			continue
+		}
+		if s.revocations != nil && jwtClaim.RegisteredClaims.ID != "" {
+			expiresAt := time.Now().Add(s.keyRetention())
+			if jwtClaim.ExpiresAt != nil {
+				expiresAt = jwtClaim.ExpiresAt.Time
+			}
+			err = s.revocations.RevokeToken(jwtClaim.RegisteredClaims.ID, expiresAt)
+			if err != nil {
+				return err
+			}
+		}
+		if s.refreshTokens != nil && jwtClaim.Token == Refresh {
+			err = s.refreshTokens.RevokeRefreshTokenFamily(jwtClaim.Family)
+			if err != nil {
+				return err
+			}
+		}
+		if jwtClaim.Token == Refresh {
+			if err := s.endSession(jwtClaim.ID, jwtClaim.Family); err != nil {
+				return err
+			}
+			if err := s.deleteOpaqueTokenFamily(jwtClaim.Family); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LogoutAll revokes every token issued to the user so far. Issue times have
+// a precision of one second, so tokens issued later within the same second
+// are revoked as well.
+func (s *UserService) LogoutAll(userID int) error {
+	if s.revocations != nil {
+		if err := s.revocations.RevokeUserTokens(userID, time.Now().Truncate(time.Second)); err != nil {
+			return err
+		}
+	}
+	return s.endSessions(userID, "")
+}
+
+func (s *UserService) sign(claim JWTClaim) (string, error) {
+	s.syncSigningKeys(signingKeySyncInterval)
+	key := s.keys.Current()
+	token := jwt.NewWithClaims(key.Method, claim)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+func (s *UserService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys.Get(kid)
+	if !ok && s.signingKeys != nil {
+		s.syncSigningKeys(unknownKeySyncInterval)
+		key, ok = s.keys.Get(kid)
+	}
+	if !ok || token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.PublicKey, nil
+}
+
+// RotateSigningKey generates a new key with the current signing method and
+// starts signing with it. Tokens signed with the previous key stay valid until
+// they expire. The key is persisted when a SigningKeyRepository is set.
+func (s *UserService) RotateSigningKey() (SigningKey, error) {
+	key, err := GenerateSigningKey(s.keys.Current().Method)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	now := time.Now()
+	if err := s.storeSigningKey(key, now); err != nil {
+		return SigningKey{}, err
+	}
+	s.keys.RotateAt(key, now)
+	return key, nil
+}
+
+// JWKS returns the public keys that can be used to verify issued tokens
+func (s *UserService) JWKS() JWKS {
+	s.syncSigningKeys(signingKeySyncInterval)
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// RefreshToken issues a new token pair for the session of the refresh token
+// and records the client as its last use
+func (s *UserService) RefreshToken(token string, userAgent string, clientIP string) (map[string]string, error) {
+	return s.refresh(token, "", userAgent, clientIP)
+}
+
+// refresh rotates a refresh token of the given OAuth client, or one issued
+// by the accounts endpoints when clientID is empty
+func (s *UserService) refresh(token string, clientID string, userAgent string, clientIP string) (map[string]string, error) {
+	jwtClaim, err := s.ValidateJWT(token, Refresh)
+	if err != nil {
+		return nil, err
+	}
+	if jwtClaim.ClientID != clientID {
+		return nil, ErrInvalidToken
+	}
+	if s.refreshTokens != nil {
+		_, err = s.refreshTokens.ConsumeRefreshToken(jwtClaim.RegisteredClaims.ID)
+		if err == ErrRefreshTokenReused {
+			if err := s.refreshTokens.RevokeRefreshTokenFamily(jwtClaim.Family); err != nil {
+				return nil, err
+			}
+			if err := s.endSession(jwtClaim.ID, jwtClaim.Family); err != nil {
+				return nil, err
+			}
+			if err := s.deleteOpaqueTokenFamily(jwtClaim.Family); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	user, err := s.repo.GetByID(jwtClaim.ID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	tokens, err := s.generateTokens(user, jwtClaim.Family, jwtClaim.ClientID, jwtClaim.Scope)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(s.Config.RefreshExpTime) * time.Minute)
+	if err := s.touchSession(jwtClaim.Family, userAgent, clientIP, expiresAt); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
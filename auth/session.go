@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionsDisabled = errors.New("sessions are not available")
+	ErrSessionNotFound  = errors.New("session not found")
+)
+
+const maxUserAgentLength = 512
+
+// Session is a login of a user on a device. Sessions are identified by the
+// refresh token family started at login, so every token refreshed from it
+// belongs to the same session.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	RevokedAt  time.Time `json:"-"`
+}
+
+type SessionRepository interface {
+	CreateSession(session Session) error
+	// TouchSession records a refresh of the session from the given client
+	// and extends it until expiresAt
+	TouchSession(id string, userAgent string, ip string, expiresAt time.Time) error
+	GetSession(id string) (Session, error)
+	// GetUserSessions returns the sessions of the user that are neither
+	// revoked nor expired
+	GetUserSessions(userID int) ([]Session, error)
+	// RevokeSession returns ErrSessionNotFound if the user has no active
+	// session with the given id
+	RevokeSession(userID int, id string) error
+	// RevokeUserSessions revokes every session of the user except exceptID,
+	// which may be empty
+	RevokeUserSessions(userID int, exceptID string) error
+}
+
+// WithSessionRepository records a session for every login so users can see
+// where they are logged in and end sessions on other devices
+func WithSessionRepository(r SessionRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.sessions = r
+	}
+}
+
+// GetSessions lists the active sessions of the user. currentID, which may be
+// empty, marks the session of the caller.
+func (s *UserService) GetSessions(userID int, currentID string) ([]Session, error) {
+	if s.sessions == nil {
+		return nil, ErrSessionsDisabled
+	}
+	sessions, err := s.sessions.GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends a session of the user. Its refresh tokens are revoked
+// and its access tokens are rejected from then on.
+func (s *UserService) RevokeSession(userID int, id string) error {
+	if s.sessions == nil {
+		return ErrSessionsDisabled
+	}
+	if err := s.sessions.RevokeSession(userID, id); err != nil {
+		return err
+	}
+	if err := s.deleteOpaqueTokenFamily(id); err != nil {
+		return err
+	}
+	if s.refreshTokens != nil {
+		return s.refreshTokens.RevokeRefreshTokenFamily(id)
+	}
+	return nil
+}
+
+func (s *UserService) startSession(userID int, family string, clientID string, userAgent string, clientIP string, expiresAt time.Time) error {
+	if s.sessions == nil {
+		return nil
+	}
+	return s.sessions.CreateSession(Session{
+		ID:        family,
+		UserID:    userID,
+		ClientID:  clientID,
+		UserAgent: truncateUserAgent(userAgent),
+		IP:        clientIP,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *UserService) touchSession(family string, userAgent string, clientIP string, expiresAt time.Time) error {
+	if s.sessions == nil || family == "" {
+		return nil
+	}
+	return s.sessions.TouchSession(family, truncateUserAgent(userAgent), clientIP, expiresAt)
+}
+
+// endSession revokes the session of a refresh token family if there is one
+func (s *UserService) endSession(userID int, family string) error {
+	if s.sessions == nil || family == "" {
+		return nil
+	}
+	err := s.sessions.RevokeSession(userID, family)
+	if err == ErrSessionNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *UserService) revokeSessions(userID int, exceptID string) error {
+	if s.sessions == nil {
+		return nil
+	}
+	return s.sessions.RevokeUserSessions(userID, exceptID)
+}
+
+// checkSession rejects tokens of revoked sessions. Tokens issued before
+// sessions were recorded have no session and are accepted.
+func (s *UserService) checkSession(claim JWTClaim) error {
+	if s.sessions == nil || claim.Family == "" {
+		return nil
+	}
+	session, err := s.sessions.GetSession(claim.Family)
+	if err == ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !session.RevokedAt.IsZero() {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		return userAgent[:maxUserAgentLength]
+	}
+	return userAgent
+}
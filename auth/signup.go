@@ -0,0 +1,58 @@
+package auth
+
+import "log"
+
+// Signup registers a new account. With Config.EnumerationSafeSignup a
+// username, phone number or email address that is already taken isn't
+// reported to the caller: an empty user and no error are returned and the
+// holder of the existing account is notified instead. The details are
+// checked before looking for existing accounts so invalid details are
+// rejected alike whether or not they are taken.
+func (s *UserService) Signup(u User) (User, error) {
+	if err := s.checkNewUser(&u); err != nil {
+		return User{}, err
+	}
+	if s.Config.EnumerationSafeSignup {
+		existing, err := s.repo.GetByUsername(u.Username)
+		if err != nil {
+			existing, err = s.repo.GetByPhone(u.Phone)
+		}
+		if err != nil && u.Email != "" {
+			existing, err = s.repo.GetByEmail(u.Email)
+		}
+		if err == nil {
+			// Hash the password anyway so duplicates take as long as new
+			// accounts
+			u.SetPasswordWith(s.hasher, u.Password)
+			s.notifyDuplicateSignup(existing)
+			return User{}, nil
+		}
+	}
+
+	user, err := s.insertUser(u)
+	if err != nil {
+		return User{}, err
+	}
+	s.sendSignupEmailVerification(user)
+	return user, nil
+}
+
+func (s *UserService) notifyDuplicateSignup(user User) {
+	if s.notifier == nil {
+		log.Printf("Duplicate signup for user %d, no notifier configured", user.ID)
+		return
+	}
+	notification := Notification{
+		Kind:    NotificationDuplicateSignup,
+		Subject: "Someone tried to sign up with your details",
+		Body: "Someone tried to create a new account with your username, phone number or email address. " +
+			"If it was you, log in to your existing account or reset your password instead. " +
+			"Otherwise you can ignore this message.",
+		Data: map[string]string{},
+	}
+	go func() {
+		if err := s.notifier.Notify(user, notification); err != nil {
+			log.Println("Error sending duplicate signup notification. Error: ", err)
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+type SMSSender interface {
+	SendSMS(phone string, message string) error
+}
+
+// LogSMSSender writes text messages to the standard logger. It is meant for
+// local development only as messages contain verification codes.
+type LogSMSSender struct{}
+
+func (LogSMSSender) SendSMS(phone string, message string) error {
+	log.Printf("SMS to %s: %s", phone, message)
+	return nil
+}
+
+// FileSMSSender appends text messages to a file so tests and local setups
+// can read the codes that would have been sent
+type FileSMSSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSMSSender(path string) *FileSMSSender {
+	return &FileSMSSender{path: path}
+}
+
+func (f *FileSMSSender) SendSMS(phone string, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// UserStatusCache remembers for a short time whether users are active, so
+// checking the account status on every authenticated request doesn't have to
+// hit the repository every time
+type UserStatusCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]userStatus
+}
+
+type userStatus struct {
+	active    bool
+	expiresAt time.Time
+}
+
+func NewUserStatusCache(ttl time.Duration) *UserStatusCache {
+	return &UserStatusCache{
+		ttl:     ttl,
+		entries: map[int]userStatus{},
+	}
+}
+
+func (c *UserStatusCache) Get(userID int) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status, ok := c.entries[userID]
+	if !ok || time.Now().After(status.expiresAt) {
+		delete(c.entries, userID)
+		return false, false
+	}
+	return status.active, true
+}
+
+func (c *UserStatusCache) Set(userID int, active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[userID] = userStatus{active: active, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *UserStatusCache) Invalidate(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// WithUserStatusCache caches the account status checked on every
+// authenticated request when Config.CheckUserStatus is set
+func WithUserStatusCache(c *UserStatusCache) UserServiceOption {
+	return func(s *UserService) {
+		s.statusCache = c
+	}
+}
+
+// Deactivate disables the account of the user and revokes all of its tokens.
+// Inactive users can't log in or refresh tokens.
+func (s *UserService) Deactivate(id int) error {
+	if err := s.repo.SetActive(id, false); err != nil {
+		return err
+	}
+	s.invalidateStatus(id)
+	return s.LogoutAll(id)
+}
+
+func (s *UserService) Activate(id int) error {
+	if err := s.repo.SetActive(id, true); err != nil {
+		return err
+	}
+	s.invalidateStatus(id)
+	return nil
+}
+
+// checkActive returns ErrUserInactive if the user is deactivated and
+// ErrInvalidToken if it was deleted
+func (s *UserService) checkActive(id int) error {
+	if s.statusCache != nil {
+		if active, ok := s.statusCache.Get(id); ok {
+			if !active {
+				return ErrUserInactive
+			}
+			return nil
+		}
+	}
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if s.statusCache != nil {
+		s.statusCache.Set(id, user.IsActive)
+	}
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	return nil
+}
+
+func (s *UserService) invalidateStatus(id int) {
+	if s.statusCache != nil {
+		s.statusCache.Invalidate(id)
+	}
+}
@@ -0,0 +1,646 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWebAuthnDisabled           = errors.New("webauthn is not available")
+	ErrInvalidWebAuthnResponse    = errors.New("invalid webauthn response")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential already registered")
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebAuthnCloned             = errors.New("webauthn authenticator may have been cloned")
+)
+
+// WebAuthn ceremonies a challenge is issued for
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+	WebAuthnMFA          = "mfa"
+)
+
+// User verification requirements of WebAuthn ceremonies
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+const (
+	webAuthnChallengeSize  = 32
+	webAuthnUserHandleSize = 32
+)
+
+// Base64URL is binary data encoded as unpadded base64url in JSON, as in the
+// JSON serialization of WebAuthn credentials
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// WebAuthnCredential is a passkey or security key registered by a user. ID
+// is the base64url encoded credential id. SecondFactor is set when the user
+// chose to be asked for the credential after logging in with a password.
+type WebAuthnCredential struct {
+	ID                string    `json:"id"`
+	UserID            int       `json:"-"`
+	Name              string    `json:"name"`
+	PublicKey         []byte    `json:"-"`
+	SignCount         uint32    `json:"-"`
+	AAGUID            string    `json:"aaguid"`
+	AttestationFormat string    `json:"attestation_format"`
+	SecondFactor      bool      `json:"second_factor"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastUsedAt        time.Time `json:"last_used_at"`
+}
+
+// WebAuthnChallenge is an issued challenge waiting for the response of an
+// authenticator. UserID is zero for passkey logins, where the user isn't
+// known until the response comes back.
+type WebAuthnChallenge struct {
+	ChallengeHash string
+	UserID        int
+	Ceremony      string
+	ExpiresAt     time.Time
+}
+
+type WebAuthnRepository interface {
+	CreateWebAuthnCredential(c WebAuthnCredential) error
+	// GetWebAuthnCredential returns ErrWebAuthnCredentialNotFound for unknown
+	// credentials
+	GetWebAuthnCredential(id string) (WebAuthnCredential, error)
+	GetUserWebAuthnCredentials(userID int) ([]WebAuthnCredential, error)
+	// UseWebAuthnCredential atomically stores the signature counter of the
+	// credential. It returns ErrWebAuthnCloned if the stored counter isn't
+	// lower, unless both are zero as for authenticators without a counter.
+	UseWebAuthnCredential(id string, signCount uint32, usedAt time.Time) error
+	DeleteWebAuthnCredential(userID int, id string) error
+	SaveWebAuthnChallenge(c WebAuthnChallenge) error
+	// ConsumeWebAuthnChallenge atomically deletes the challenge. It returns
+	// ErrInvalidToken if it is unknown or expired.
+	ConsumeWebAuthnChallenge(challengeHash string) (WebAuthnChallenge, error)
+	// GetWebAuthnUserHandle returns ErrWebAuthnCredentialNotFound when the
+	// user has no handle yet
+	GetWebAuthnUserHandle(userID int) ([]byte, error)
+	// CreateWebAuthnUserHandle stores the handle unless the user already has
+	// one and returns the handle of the user
+	CreateWebAuthnUserHandle(userID int, handle []byte) ([]byte, error)
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CredentialCreationOptions are the publicKey options of
+// navigator.credentials.create
+type CredentialCreationOptions struct {
+	RP                     RelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity     `json:"user"`
+	Challenge              Base64URL              `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// CredentialRequestOptions are the publicKey options of
+// navigator.credentials.get
+type CredentialRequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationCredential is the JSON serialization of the credential returned
+// by navigator.credentials.create
+type RegistrationCredential struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId" validate:"required"`
+	Type     string    `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+		AttestationObject Base64URL `json:"attestationObject" validate:"required"`
+	} `json:"response"`
+}
+
+// AssertionCredential is the JSON serialization of the credential returned
+// by navigator.credentials.get
+type AssertionCredential struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId" validate:"required"`
+	Type     string    `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData Base64URL `json:"authenticatorData" validate:"required"`
+		Signature         Base64URL `json:"signature" validate:"required"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+type WebAuthnRegistrationForm struct {
+	Name         string                 `json:"name"`
+	SecondFactor bool                   `json:"second_factor"`
+	Credential   RegistrationCredential `json:"credential"`
+}
+
+func (f *WebAuthnRegistrationForm) Validate() error {
+	return Validate(f)
+}
+
+type WebAuthnLoginForm struct {
+	Credential AssertionCredential `json:"credential"`
+}
+
+func (f *WebAuthnLoginForm) Validate() error {
+	return Validate(f)
+}
+
+type WebAuthnMFABeginForm struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+func (f *WebAuthnMFABeginForm) Validate() error {
+	return Validate(f)
+}
+
+type WebAuthnMFAForm struct {
+	MFAToken   string              `json:"mfa_token" validate:"required"`
+	Credential AssertionCredential `json:"credential"`
+}
+
+func (f *WebAuthnMFAForm) Validate() error {
+	return Validate(f)
+}
+
+// collectedClientData is the client data signed along with the authenticator
+// data
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// WithWebAuthnRepository enables passkeys and security keys, both for logging
+// in without a password and as a second factor
+func WithWebAuthnRepository(r WebAuthnRepository) UserServiceOption {
+	return func(s *UserService) {
+		s.webAuthn = r
+	}
+}
+
+// BeginWebAuthnRegistration returns the options to create a new credential
+// for the user with navigator.credentials.create
+func (s *UserService) BeginWebAuthnRegistration(userID int) (CredentialCreationOptions, error) {
+	if s.webAuthn == nil {
+		return CredentialCreationOptions{}, ErrWebAuthnDisabled
+	}
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return CredentialCreationOptions{}, err
+	}
+	credentials, err := s.webAuthn.GetUserWebAuthnCredentials(userID)
+	if err != nil {
+		return CredentialCreationOptions{}, err
+	}
+	handle, err := s.webAuthnUserHandle(userID)
+	if err != nil {
+		return CredentialCreationOptions{}, err
+	}
+	challenge, err := s.newWebAuthnChallenge(userID, WebAuthnRegistration)
+	if err != nil {
+		return CredentialCreationOptions{}, err
+	}
+
+	displayName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if displayName == "" {
+		displayName = user.Username
+	}
+	return CredentialCreationOptions{
+		RP:        RelyingParty{ID: s.Config.WebAuthnRPID, Name: s.Config.WebAuthnRPName},
+		User:      WebAuthnUserEntity{ID: handle, Name: user.Username, DisplayName: displayName},
+		Challenge: challenge,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: COSEAlgES256},
+			{Type: "public-key", Alg: COSEAlgEdDSA},
+			{Type: "public-key", Alg: COSEAlgRS256},
+		},
+		Timeout:            s.webAuthnTimeout(),
+		ExcludeCredentials: credentialDescriptors(credentials),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: s.Config.WebAuthnUserVerification,
+		},
+		Attestation: s.Config.WebAuthnAttestation,
+	}, nil
+}
+
+// FinishWebAuthnRegistration verifies the credential created with the
+// options of BeginWebAuthnRegistration and stores it. Attestation statements
+// in the none and packed formats are accepted. When secondFactor is set the
+// credential is also required after password logins.
+func (s *UserService) FinishWebAuthnRegistration(userID int, name string, secondFactor bool, c RegistrationCredential) (WebAuthnCredential, error) {
+	if s.webAuthn == nil {
+		return WebAuthnCredential{}, ErrWebAuthnDisabled
+	}
+	clientDataHash, err := s.checkClientData(c.Response.ClientDataJSON, "webauthn.create", WebAuthnRegistration, userID)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	attestation, err := parseAttestationObject(c.Response.AttestationObject)
+	if err != nil {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+	authData := attestation.AuthData
+	if err := s.checkAuthenticatorData(authData, s.Config.WebAuthnUserVerification); err != nil {
+		return WebAuthnCredential{}, err
+	}
+	if !bytes.Equal(authData.CredentialID, c.RawID) {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+	key, err := ParseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	if err := attestation.verify(key, clientDataHash); err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	if _, err := s.webAuthn.GetWebAuthnCredential(id); err == nil {
+		return WebAuthnCredential{}, ErrWebAuthnCredentialExists
+	}
+	if name == "" {
+		name = "Passkey"
+	}
+	credential := WebAuthnCredential{
+		ID:                id,
+		UserID:            userID,
+		Name:              name,
+		PublicKey:         authData.PublicKey,
+		SignCount:         authData.SignCount,
+		AAGUID:            hex.EncodeToString(authData.AAGUID),
+		AttestationFormat: attestation.Format,
+		SecondFactor:      secondFactor,
+		CreatedAt:         time.Now(),
+	}
+	if err := s.webAuthn.CreateWebAuthnCredential(credential); err != nil {
+		return WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// BeginWebAuthnLogin returns the options to log in with a passkey using
+// navigator.credentials.get. No credentials are listed so the authenticator
+// offers the passkeys it holds for the relying party. Every call stores a
+// challenge, so calls are counted against the client IP like failed logins
+// until a passkey login from it succeeds.
+func (s *UserService) BeginWebAuthnLogin(clientIP string) (CredentialRequestOptions, error) {
+	if s.webAuthn == nil {
+		return CredentialRequestOptions{}, ErrWebAuthnDisabled
+	}
+	keys := webAuthnLoginKeys(clientIP, s.loginThrottle.IPMaxAttempts)
+	if err := s.checkLoginThrottle(keys); err != nil {
+		return CredentialRequestOptions{}, err
+	}
+	if err := s.recordLoginFailure(keys); err != nil {
+		return CredentialRequestOptions{}, err
+	}
+	challenge, err := s.newWebAuthnChallenge(0, WebAuthnLogin)
+	if err != nil {
+		return CredentialRequestOptions{}, err
+	}
+	return CredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.webAuthnTimeout(),
+		RPID:             s.Config.WebAuthnRPID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: UserVerificationRequired,
+	}, nil
+}
+
+// FinishWebAuthnLogin logs the owner of the passkey in. The authenticator
+// must have verified the user, making the passkey a complete login on its
+// own.
+func (s *UserService) FinishWebAuthnLogin(c AssertionCredential, clientIP string) (User, error) {
+	if s.webAuthn == nil {
+		return User{}, ErrWebAuthnDisabled
+	}
+	credential, err := s.verifyAssertion(c, WebAuthnLogin, 0, UserVerificationRequired)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := s.repo.GetByID(credential.UserID)
+	if err != nil {
+		return User{}, ErrInvalidWebAuthnResponse
+	}
+	if err := s.checkCanLogin(user); err != nil {
+		return User{}, err
+	}
+	if s.loginAttempts != nil {
+		for _, k := range webAuthnLoginKeys(clientIP, 0) {
+			if err := s.loginAttempts.ResetLoginAttempts(k.key); err != nil {
+				log.Println("Error resetting passkey login attempts. Error: ", err)
+			}
+		}
+	}
+	return user, nil
+}
+
+// BeginWebAuthnMFA returns the options to complete a password login with a
+// registered credential as the second factor
+func (s *UserService) BeginWebAuthnMFA(mfaToken string) (CredentialRequestOptions, error) {
+	if s.webAuthn == nil {
+		return CredentialRequestOptions{}, ErrWebAuthnDisabled
+	}
+	claim, err := s.ValidateJWT(mfaToken, MFAPending)
+	if err != nil {
+		return CredentialRequestOptions{}, ErrInvalidToken
+	}
+	credentials, err := s.secondFactorCredentials(claim.ID)
+	if err != nil {
+		return CredentialRequestOptions{}, err
+	}
+	if len(credentials) == 0 {
+		return CredentialRequestOptions{}, ErrMFANotEnrolled
+	}
+	challenge, err := s.newWebAuthnChallenge(claim.ID, WebAuthnMFA)
+	if err != nil {
+		return CredentialRequestOptions{}, err
+	}
+	return CredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.webAuthnTimeout(),
+		RPID:             s.Config.WebAuthnRPID,
+		AllowCredentials: credentialDescriptors(credentials),
+		UserVerification: s.Config.WebAuthnUserVerification,
+	}, nil
+}
+
+// FinishWebAuthnMFA completes a password login with the response to the
+// options of BeginWebAuthnMFA. Like VerifyMFA the token can only be used
+// once when a RevocationStore is configured.
+func (s *UserService) FinishWebAuthnMFA(mfaToken string, c AssertionCredential) (User, error) {
+	if s.webAuthn == nil {
+		return User{}, ErrWebAuthnDisabled
+	}
+	claim, err := s.ValidateJWT(mfaToken, MFAPending)
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	credential, err := s.verifyAssertion(c, WebAuthnMFA, claim.ID, s.Config.WebAuthnUserVerification)
+	if err != nil {
+		return User{}, err
+	}
+	if !credential.SecondFactor {
+		return User{}, ErrInvalidWebAuthnResponse
+	}
+	user, err := s.repo.GetByID(claim.ID)
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	if err := s.checkCanLogin(user); err != nil {
+		return User{}, err
+	}
+	if s.revocations != nil {
+		if err := s.revocations.RevokeToken(claim.RegisteredClaims.ID, claim.ExpiresAt.Time); err != nil {
+			return User{}, err
+		}
+	}
+	return user, nil
+}
+
+// GetWebAuthnCredentials lists the credentials registered by the user
+func (s *UserService) GetWebAuthnCredentials(userID int) ([]WebAuthnCredential, error) {
+	if s.webAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	return s.webAuthn.GetUserWebAuthnCredentials(userID)
+}
+
+func (s *UserService) DeleteWebAuthnCredential(userID int, id string) error {
+	if s.webAuthn == nil {
+		return ErrWebAuthnDisabled
+	}
+	return s.webAuthn.DeleteWebAuthnCredential(userID, id)
+}
+
+// secondFactorCredentials returns the credentials the user registered to be
+// used as a second factor
+func (s *UserService) secondFactorCredentials(userID int) ([]WebAuthnCredential, error) {
+	if s.webAuthn == nil {
+		return nil, nil
+	}
+	credentials, err := s.webAuthn.GetUserWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	secondFactors := []WebAuthnCredential{}
+	for _, c := range credentials {
+		if c.SecondFactor {
+			secondFactors = append(secondFactors, c)
+		}
+	}
+	return secondFactors, nil
+}
+
+// webAuthnLoginKeys are the login attempt keys counting the passkey login
+// challenges issued to a client IP
+func webAuthnLoginKeys(clientIP string, maxAttempts int) []loginAttemptKey {
+	if clientIP == "" {
+		return nil
+	}
+	return []loginAttemptKey{{"webauthn:" + clientIP, maxAttempts, false}}
+}
+
+// verifyAssertion checks the response of an authenticator to a challenge of
+// the ceremony and records the use of the credential. userID is the user the
+// challenge was issued to, or zero for passkey logins.
+func (s *UserService) verifyAssertion(c AssertionCredential, ceremony string, userID int, userVerification string) (WebAuthnCredential, error) {
+	clientDataHash, err := s.checkClientData(c.Response.ClientDataJSON, "webauthn.get", ceremony, userID)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	credential, err := s.webAuthn.GetWebAuthnCredential(base64.RawURLEncoding.EncodeToString(c.RawID))
+	if err != nil {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+	if userID != 0 && credential.UserID != userID {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+	if len(c.Response.UserHandle) > 0 {
+		handle, err := s.webAuthn.GetWebAuthnUserHandle(credential.UserID)
+		if err != nil || !bytes.Equal(c.Response.UserHandle, handle) {
+			return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+		}
+	}
+
+	authData, err := parseAuthenticatorData(c.Response.AuthenticatorData)
+	if err != nil {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+	if err := s.checkAuthenticatorData(authData, userVerification); err != nil {
+		return WebAuthnCredential{}, err
+	}
+	key, err := ParseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	signed := append(append([]byte(nil), authData.Raw...), clientDataHash...)
+	if !key.Verify(signed, c.Response.Signature) {
+		return WebAuthnCredential{}, ErrInvalidWebAuthnResponse
+	}
+
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return WebAuthnCredential{}, ErrWebAuthnCloned
+	}
+	if err := s.webAuthn.UseWebAuthnCredential(credential.ID, authData.SignCount, time.Now()); err != nil {
+		return WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// checkClientData verifies the client data of a response and consumes the
+// challenge it answers. It returns the hash of the client data, which the
+// authenticator signed.
+func (s *UserService) checkClientData(clientDataJSON []byte, clientDataType string, ceremony string, userID int) ([]byte, error) {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	if clientData.Type != clientDataType || clientData.CrossOrigin || !s.allowedWebAuthnOrigin(clientData.Origin) {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	challenge, err := s.webAuthn.ConsumeWebAuthnChallenge(hashToken(clientData.Challenge))
+	if err != nil {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	if challenge.Ceremony != ceremony || challenge.UserID != userID || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	sum := sha256.Sum256(clientDataJSON)
+	return sum[:], nil
+}
+
+func (s *UserService) checkAuthenticatorData(authData authenticatorData, userVerification string) error {
+	if !bytes.Equal(authData.RPIDHash, rpIDHash(s.Config.WebAuthnRPID)) || !authData.UserPresent() {
+		return ErrInvalidWebAuthnResponse
+	}
+	if userVerification == UserVerificationRequired && !authData.UserVerified() {
+		return ErrInvalidWebAuthnResponse
+	}
+	return nil
+}
+
+func (s *UserService) allowedWebAuthnOrigin(origin string) bool {
+	origins := s.Config.WebAuthnOrigins
+	if len(origins) == 0 {
+		origins = []string{"https://" + s.Config.WebAuthnRPID}
+	}
+	for _, allowed := range origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *UserService) newWebAuthnChallenge(userID int, ceremony string) (Base64URL, error) {
+	raw := make([]byte, webAuthnChallengeSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	err := s.webAuthn.SaveWebAuthnChallenge(WebAuthnChallenge{
+		ChallengeHash: hashToken(base64.RawURLEncoding.EncodeToString(raw)),
+		UserID:        userID,
+		Ceremony:      ceremony,
+		ExpiresAt:     time.Now().Add(time.Duration(s.Config.WebAuthnChallengeExpTime) * time.Minute),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// webAuthnTimeout is the time in milliseconds the browser waits for the
+// authenticator, which is how long the challenge is valid
+func (s *UserService) webAuthnTimeout() int {
+	return int((time.Duration(s.Config.WebAuthnChallengeExpTime) * time.Minute).Milliseconds())
+}
+
+// webAuthnUserHandle returns the random handle identifying the user to
+// authenticators without personal information, creating it on first use
+func (s *UserService) webAuthnUserHandle(userID int) (Base64URL, error) {
+	handle, err := s.webAuthn.GetWebAuthnUserHandle(userID)
+	if err == nil {
+		return handle, nil
+	}
+	if err != ErrWebAuthnCredentialNotFound {
+		return nil, err
+	}
+	handle = make([]byte, webAuthnUserHandleSize)
+	if _, err := rand.Read(handle); err != nil {
+		return nil, err
+	}
+	return s.webAuthn.CreateWebAuthnUserHandle(userID, handle)
+}
+
+func credentialDescriptors(credentials []WebAuthnCredential) []CredentialDescriptor {
+	descriptors := []CredentialDescriptor{}
+	for _, c := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(c.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, CredentialDescriptor{Type: "public-key", ID: id})
+	}
+	return descriptors
+}
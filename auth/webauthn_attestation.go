@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidAttestation = errors.New("invalid webauthn attestation")
+
+// Authenticator data flags
+const (
+	authDataUserPresent  = 0x01
+	authDataUserVerified = 0x04
+	authDataAttested     = 0x40
+	authDataExtensions   = 0x80
+)
+
+// Attestation statement formats accepted at registration
+const (
+	AttestationNone   = "none"
+	AttestationPacked = "packed"
+)
+
+// idFidoGenCeAAGUID is the certificate extension holding the AAGUID of the
+// authenticator model in packed attestation certificates
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// authenticatorData is the parsed data signed by an authenticator
+type authenticatorData struct {
+	Raw          []byte
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (d authenticatorData) UserPresent() bool {
+	return d.Flags&authDataUserPresent != 0
+}
+
+func (d authenticatorData) UserVerified() bool {
+	return d.Flags&authDataUserVerified != 0
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, ErrInvalidAttestation
+	}
+	d := authenticatorData{
+		Raw:       data,
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if d.Flags&authDataAttested != 0 {
+		if len(rest) < 18 {
+			return authenticatorData{}, ErrInvalidAttestation
+		}
+		d.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return authenticatorData{}, ErrInvalidAttestation
+		}
+		d.CredentialID, rest = rest[:idLength], rest[idLength:]
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, ErrInvalidAttestation
+		}
+		d.PublicKey, rest = rest[:len(rest)-len(afterKey)], afterKey
+	}
+	if d.Flags&authDataExtensions != 0 {
+		_, afterExtensions, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, ErrInvalidAttestation
+		}
+		rest = afterExtensions
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, ErrInvalidAttestation
+	}
+	return d, nil
+}
+
+// attestationObject is the CBOR object returned by an authenticator when a
+// credential is created
+type attestationObject struct {
+	Format   string
+	AttStmt  map[interface{}]interface{}
+	AuthData authenticatorData
+}
+
+func parseAttestationObject(data []byte) (attestationObject, error) {
+	m, err := decodeCBORMap(data)
+	if err != nil {
+		return attestationObject{}, ErrInvalidAttestation
+	}
+	format, _ := m["fmt"].(string)
+	attStmt, _ := m["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := m["authData"].([]byte)
+	if format == "" || attStmt == nil {
+		return attestationObject{}, ErrInvalidAttestation
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return attestationObject{}, err
+	}
+	if authData.CredentialID == nil {
+		return attestationObject{}, ErrInvalidAttestation
+	}
+	return attestationObject{Format: format, AttStmt: attStmt, AuthData: authData}, nil
+}
+
+// verify checks the attestation statement. Packed attestation certificates
+// are checked against the requirements of the WebAuthn specification but not
+// chained to a trust anchor, so they prove which key signed the credential
+// and not which authenticator model created it.
+func (a attestationObject) verify(key COSEKey, clientDataHash []byte) error {
+	switch a.Format {
+	case AttestationNone:
+		if len(a.AttStmt) != 0 {
+			return ErrInvalidAttestation
+		}
+		return nil
+	case AttestationPacked:
+		return a.verifyPacked(key, clientDataHash)
+	}
+	return ErrInvalidAttestation
+}
+
+func (a attestationObject) verifyPacked(key COSEKey, clientDataHash []byte) error {
+	alg, _ := a.AttStmt["alg"].(int64)
+	sig, _ := a.AttStmt["sig"].([]byte)
+	if sig == nil {
+		return ErrInvalidAttestation
+	}
+	signed := append(append([]byte(nil), a.AuthData.Raw...), clientDataHash...)
+
+	x5c, hasCertificates := a.AttStmt["x5c"].([]interface{})
+	if !hasCertificates {
+		// Self attestation is signed by the credential key itself
+		if alg != key.Alg || !key.Verify(signed, sig) {
+			return ErrInvalidAttestation
+		}
+		return nil
+	}
+
+	if len(x5c) == 0 {
+		return ErrInvalidAttestation
+	}
+	der, _ := x5c[0].([]byte)
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ErrInvalidAttestation
+	}
+	if !verifyCOSESignature(alg, certificate.PublicKey, signed, sig) {
+		return ErrInvalidAttestation
+	}
+	return checkPackedCertificate(certificate, a.AuthData.AAGUID)
+}
+
+// checkPackedCertificate enforces the packed attestation certificate
+// requirements of the WebAuthn specification
+func checkPackedCertificate(certificate *x509.Certificate, aaguid []byte) error {
+	subject := certificate.Subject
+	if certificate.Version != 3 || certificate.IsCA || len(subject.Country) == 0 ||
+		len(subject.Organization) == 0 || len(subject.CommonName) == 0 ||
+		len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return ErrInvalidAttestation
+	}
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(extension.Value, &value); err != nil || extension.Critical || !bytes.Equal(value, aaguid) {
+			return ErrInvalidAttestation
+		}
+	}
+	return nil
+}
+
+// rpIDHash is the hash authenticators include in their data for the
+// relying party id
+func rpIDHash(rpID string) []byte {
+	sum := sha256.Sum256([]byte(rpID))
+	return sum[:]
+}
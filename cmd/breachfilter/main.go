@@ -0,0 +1,99 @@
+// Command breachfilter builds the Bloom filter used to reject breached
+// passwords from a Pwned Passwords SHA-1 file.
+//
+//	go run ./cmd/breachfilter -in pwned-passwords-sha1-ordered-by-hash-v8.txt -out breached.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mohaali482/goAuth/auth"
+)
+
+func main() {
+	in := flag.String("in", "", "Pwned Passwords file of SHA1:COUNT lines")
+	out := flag.String("out", "breached.bloom", "where the filter is written")
+	rate := flag.Float64("rate", 0.001, "false positive rate of the filter")
+	minCount := flag.Int("min-count", 1, "only include passwords seen at least this many times")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	n, err := countHashes(*in, *minCount)
+	if err != nil {
+		log.Fatalf("Error reading %s: %s", *in, err)
+	}
+	log.Printf("Building filter for %d hashes", n)
+
+	filter := auth.NewBloomFilter(n, *rate)
+	err = readHashes(*in, *minCount, func(line string) error {
+		sum, err := auth.ParseSHA1Hash(line)
+		if err != nil {
+			return err
+		}
+		filter.AddHash(sum)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error reading %s: %s", *in, err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Error creating %s: %s", *out, err)
+	}
+	w := bufio.NewWriter(f)
+	if _, err := filter.WriteTo(w); err != nil {
+		log.Fatalf("Error writing %s: %s", *out, err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Error writing %s: %s", *out, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Error writing %s: %s", *out, err)
+	}
+	log.Printf("Filter written to %s", *out)
+}
+
+func countHashes(path string, minCount int) (uint64, error) {
+	var n uint64
+	err := readHashes(path, minCount, func(string) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// readHashes calls fn for every line of the file seen at least minCount times
+func readHashes(path string, minCount int, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if minCount > 1 {
+			_, count, _ := strings.Cut(line, ":")
+			if c, err := strconv.Atoi(count); err != nil || c < minCount {
+				continue
+			}
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
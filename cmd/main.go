@@ -1,31 +1,105 @@
-package main
-
-import (
-	"log"
-
-	"github.com/mohaali482/goAuth/auth"
-	authGorm "github.com/mohaali482/goAuth/auth/gorm"
-	"github.com/mohaali482/goAuth/config"
-	"github.com/mohaali482/goAuth/internal/api"
-	"github.com/mohaali482/goAuth/internal/http/gin"
-)
-
-func main() {
-	appConfig, err := config.NewConfig()
-	if err != nil {
-		panic(err)
-	}
-	r, err := authGorm.NewGormRepository(appConfig.DB)
-	if err != nil {
-		panic(err)
-	}
-	s := auth.NewUserService(r, appConfig)
-	h := gin.Handlers(*s)
-	// app := fiberHandler.App(*s)
-
-	err = api.Start(appConfig.Port, h)
-	// err = app.Listen(appConfig.Port)
-	if err != nil {
-		log.Fatalf("Error starting server: %s", err)
-	}
-}
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mohaali482/goAuth/auth"
+	authGorm "github.com/mohaali482/goAuth/auth/gorm"
+	"github.com/mohaali482/goAuth/config"
+	"github.com/mohaali482/goAuth/internal/api"
+	"github.com/mohaali482/goAuth/internal/http/gin"
+)
+
+func main() {
+	appConfig, err := config.NewConfig()
+	if err != nil {
+		panic(err)
+	}
+	r, err := authGorm.NewGormRepository(appConfig.DB)
+	if err != nil {
+		panic(err)
+	}
+	key, err := auth.LoadSigningKey(appConfig)
+	if err != nil {
+		panic(err)
+	}
+	hasher, err := auth.NewPasswordHasher(appConfig)
+	if err != nil {
+		panic(err)
+	}
+	var revocations auth.RevocationStore = r
+	if appConfig.RevocationStore == "memory" {
+		revocations = auth.NewMemoryRevocationStore(time.Duration(appConfig.RefreshExpTime) * time.Minute)
+	}
+	var loginAttempts auth.LoginAttemptStore = r
+	if appConfig.LoginAttemptStore == "memory" {
+		loginAttempts = auth.NewMemoryLoginAttemptStore()
+	}
+	var sms auth.SMSSender = auth.LogSMSSender{}
+	if appConfig.SMSSender == "file" {
+		sms = auth.NewFileSMSSender(appConfig.SMSFile)
+	}
+	options := []auth.UserServiceOption{
+		auth.WithSigningKey(key),
+		auth.WithPasswordHasher(hasher),
+		auth.WithRefreshTokenRepository(r),
+		auth.WithRevocationStore(revocations),
+		auth.WithLoginAttemptStore(loginAttempts),
+		auth.WithRoleRepository(r),
+		auth.WithPasswordResetRepository(r),
+		auth.WithNotifier(auth.LogNotifier{}),
+		auth.WithMFARepository(r),
+		auth.WithPhoneVerificationRepository(r),
+		auth.WithSMSSender(sms),
+		auth.WithEmailVerificationRepository(r),
+		auth.WithLoginCodeRepository(r),
+		auth.WithWebAuthnRepository(r),
+		auth.WithSessionRepository(r),
+		auth.WithOpaqueTokenRepository(r),
+		auth.WithOAuthRepository(r),
+		auth.WithSigningKeyRepository(r),
+	}
+	if appConfig.CheckUserStatus && appConfig.UserStatusCacheTime > 0 {
+		cache := auth.NewUserStatusCache(time.Duration(appConfig.UserStatusCacheTime) * time.Second)
+		options = append(options, auth.WithUserStatusCache(cache))
+	}
+	if appConfig.BreachedPasswordsFile != "" {
+		breached, err := auth.LoadBreachedPasswordChecker(appConfig.BreachedPasswordsFile)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, auth.WithBreachedPasswordChecker(breached))
+	}
+	s := auth.NewUserService(r, appConfig, options...)
+	if err := s.SyncSigningKeys(); err != nil {
+		panic(err)
+	}
+	go rotateKeysOnSignal(s)
+	h := gin.Handlers(*s)
+	// app := fiberHandler.App(*s)
+
+	err = api.Start(appConfig.Port, h)
+	// err = app.Listen(appConfig.Port)
+	if err != nil {
+		log.Fatalf("Error starting server: %s", err)
+	}
+}
+
+// rotateKeysOnSignal rotates the token signing key every time the process
+// receives SIGHUP
+func rotateKeysOnSignal(s *auth.UserService) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		key, err := s.RotateSigningKey()
+		if err != nil {
+			log.Println("Error rotating signing key. Error: ", err)
+			continue
+		}
+		log.Printf("Signing key rotated, new kid %s", key.ID)
+	}
+}
@@ -1,43 +1,237 @@
-package config
-
-import (
-	"os"
-	"strconv"
-
-	"github.com/joho/godotenv"
-)
-
-type Config struct {
-	Port           string
-	DB             string
-	Secret         string
-	AccessExpTime  int
-	RefreshExpTime int
-}
-
-func NewConfig() (*Config, error) {
-	err := godotenv.Load()
-	if err != nil {
-		panic(err)
-	}
-
-	accessExpTime, err := strconv.Atoi(os.Getenv("AccessExpTime"))
-	if err != nil {
-		panic(err)
-	}
-
-	refreshExpTime, err := strconv.Atoi(os.Getenv("RefreshExpTime"))
-	if err != nil {
-		panic(err)
-	}
-
-	config := &Config{
-		Port:           os.Getenv("PORT"),
-		DB:             os.Getenv("DB"),
-		Secret:         os.Getenv("SECRET"),
-		AccessExpTime:  accessExpTime,
-		RefreshExpTime: refreshExpTime,
-	}
-
-	return config, nil
-}
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	TokenLookupCookie = "cookie"
+	TokenLookupHeader = "header"
+
+	TokenDeliveryCookie = "cookie"
+	TokenDeliveryBody   = "body"
+	TokenDeliveryBoth   = "both"
+
+	TokenFormatJWT    = "jwt"
+	TokenFormatOpaque = "opaque"
+)
+
+// Config is the configuration read from the environment. SigningMethod,
+// PrivateKeyFile and KeyID only set the signing key used until the first
+// rotation. Rotated keys, private keys included, are stored unencrypted in
+// the database and sign tokens from then on, so changing these settings has
+// no effect while stored keys remain.
+type Config struct {
+	Port                            string
+	DB                              string
+	Secret                          string
+	AccessExpTime                   int
+	RefreshExpTime                  int
+	SigningMethod                   string
+	PrivateKeyFile                  string
+	KeyID                           string
+	RevocationStore                 string
+	Issuer                          string
+	Audience                        []string
+	TokenLookup                     []string
+	TokenDelivery                   string
+	LoadPrincipalUser               bool
+	PasswordResetExpTime            int
+	PasswordResetURL                string
+	PasswordResetResendInterval     int
+	PasswordHasher                  string
+	BcryptCost                      int
+	Argon2Time                      int
+	Argon2Memory                    int
+	Argon2Threads                   int
+	ScryptCost                      int
+	PasswordMinLength               int
+	PasswordMaxLength               int
+	PasswordRequireUpper            bool
+	PasswordRequireLower            bool
+	PasswordRequireDigit            bool
+	PasswordRequireSymbol           bool
+	PasswordDisallowUserInfo        bool
+	PasswordMinScore                int
+	BreachedPasswordsFile           string
+	LoginAttemptStore               string
+	LoginMaxAttempts                int
+	LoginIPMaxAttempts              int
+	LoginBackoff                    int
+	LoginLockoutTime                int
+	CheckUserStatus                 bool
+	UserStatusCacheTime             int
+	EnumerationSafeSignup           bool
+	MFATokenExpTime                 int
+	SMSSender                       string
+	SMSFile                         string
+	PhoneVerificationExpTime        int
+	PhoneVerificationMaxAttempts    int
+	PhoneVerificationResendInterval int
+	RequirePhoneVerification        bool
+	RequireEmail                    bool
+	EmailVerificationExpTime        int
+	EmailVerificationURL            string
+	PasswordlessLogin               bool
+	PasswordlessCodeExpTime         int
+	PasswordlessMaxAttempts         int
+	PasswordlessResendInterval      int
+	PasswordlessURL                 string
+	WebAuthnRPID                    string
+	WebAuthnRPName                  string
+	WebAuthnOrigins                 []string
+	WebAuthnUserVerification        string
+	WebAuthnAttestation             string
+	WebAuthnChallengeExpTime        int
+	TokenFormat                     string
+	IntrospectionClientID           string
+	IntrospectionClientSecret       string
+	OAuthCodeExpTime                int
+	TrustedProxies                  []string
+	ProxyHeader                     string
+}
+
+func NewConfig() (*Config, error) {
+	err := godotenv.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	accessExpTime, err := strconv.Atoi(os.Getenv("AccessExpTime"))
+	if err != nil {
+		panic(err)
+	}
+
+	refreshExpTime, err := strconv.Atoi(os.Getenv("RefreshExpTime"))
+	if err != nil {
+		panic(err)
+	}
+
+	config := &Config{
+		Port:                            os.Getenv("PORT"),
+		DB:                              os.Getenv("DB"),
+		Secret:                          os.Getenv("SECRET"),
+		AccessExpTime:                   accessExpTime,
+		RefreshExpTime:                  refreshExpTime,
+		SigningMethod:                   getEnv("SIGNING_METHOD", "HS256"),
+		PrivateKeyFile:                  os.Getenv("PRIVATE_KEY_FILE"),
+		KeyID:                           os.Getenv("KEY_ID"),
+		RevocationStore:                 getEnv("REVOCATION_STORE", "database"),
+		Issuer:                          getEnv("ISSUER", "goAuth"),
+		Audience:                        getEnvList("AUDIENCE"),
+		TokenLookup:                     getEnvList("TOKEN_LOOKUP"),
+		TokenDelivery:                   getEnv("TOKEN_DELIVERY", TokenDeliveryCookie),
+		LoadPrincipalUser:               getEnvBool("LOAD_PRINCIPAL_USER", false),
+		PasswordResetExpTime:            getEnvInt("PASSWORD_RESET_EXP_TIME", 15),
+		PasswordResetURL:                os.Getenv("PASSWORD_RESET_URL"),
+		PasswordResetResendInterval:     getEnvInt("PASSWORD_RESET_RESEND_INTERVAL", 60),
+		PasswordHasher:                  getEnv("PASSWORD_HASHER", "argon2id"),
+		BcryptCost:                      getEnvInt("BCRYPT_COST", 10),
+		Argon2Time:                      getEnvInt("ARGON2_TIME", 3),
+		Argon2Memory:                    getEnvInt("ARGON2_MEMORY", 64*1024),
+		Argon2Threads:                   getEnvInt("ARGON2_THREADS", 2),
+		ScryptCost:                      getEnvInt("SCRYPT_COST", 15),
+		PasswordMinLength:               getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:               getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordRequireUpper:            getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+		PasswordRequireLower:            getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+		PasswordRequireDigit:            getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		PasswordRequireSymbol:           getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordDisallowUserInfo:        getEnvBool("PASSWORD_DISALLOW_USER_INFO", true),
+		PasswordMinScore:                getEnvInt("PASSWORD_MIN_SCORE", 2),
+		BreachedPasswordsFile:           os.Getenv("BREACHED_PASSWORDS_FILE"),
+		LoginAttemptStore:               getEnv("LOGIN_ATTEMPT_STORE", "database"),
+		LoginMaxAttempts:                getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:              getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 50),
+		LoginBackoff:                    getEnvInt("LOGIN_BACKOFF", 1),
+		LoginLockoutTime:                getEnvInt("LOGIN_LOCKOUT_TIME", 15),
+		CheckUserStatus:                 getEnvBool("CHECK_USER_STATUS", false),
+		UserStatusCacheTime:             getEnvInt("USER_STATUS_CACHE_TIME", 30),
+		EnumerationSafeSignup:           getEnvBool("ENUMERATION_SAFE_SIGNUP", false),
+		MFATokenExpTime:                 getEnvInt("MFA_TOKEN_EXP_TIME", 5),
+		SMSSender:                       getEnv("SMS_SENDER", "log"),
+		SMSFile:                         getEnv("SMS_FILE", "sms.log"),
+		PhoneVerificationExpTime:        getEnvInt("PHONE_VERIFICATION_CODE_EXP_TIME", 10),
+		PhoneVerificationMaxAttempts:    getEnvInt("PHONE_VERIFICATION_MAX_ATTEMPTS", 5),
+		PhoneVerificationResendInterval: getEnvInt("PHONE_VERIFICATION_RESEND_INTERVAL", 60),
+		RequirePhoneVerification:        getEnvBool("REQUIRE_PHONE_VERIFICATION", false),
+		RequireEmail:                    getEnvBool("REQUIRE_EMAIL", false),
+		EmailVerificationExpTime:        getEnvInt("EMAIL_VERIFICATION_EXP_TIME", 24*60),
+		EmailVerificationURL:            os.Getenv("EMAIL_VERIFICATION_URL"),
+		PasswordlessLogin:               getEnvBool("PASSWORDLESS_LOGIN", false),
+		PasswordlessCodeExpTime:         getEnvInt("PASSWORDLESS_CODE_EXP_TIME", 10),
+		PasswordlessMaxAttempts:         getEnvInt("PASSWORDLESS_MAX_ATTEMPTS", 5),
+		PasswordlessResendInterval:      getEnvInt("PASSWORDLESS_RESEND_INTERVAL", 60),
+		PasswordlessURL:                 os.Getenv("PASSWORDLESS_URL"),
+		WebAuthnRPID:                    getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:                  os.Getenv("WEBAUTHN_RP_NAME"),
+		WebAuthnOrigins:                 getEnvList("WEBAUTHN_ORIGINS"),
+		WebAuthnUserVerification:        getEnv("WEBAUTHN_USER_VERIFICATION", "preferred"),
+		WebAuthnAttestation:             getEnv("WEBAUTHN_ATTESTATION", "none"),
+		WebAuthnChallengeExpTime:        getEnvInt("WEBAUTHN_CHALLENGE_EXP_TIME", 5),
+		TokenFormat:                     getEnv("TOKEN_FORMAT", TokenFormatJWT),
+		IntrospectionClientID:           os.Getenv("INTROSPECTION_CLIENT_ID"),
+		IntrospectionClientSecret:       os.Getenv("INTROSPECTION_CLIENT_SECRET"),
+		OAuthCodeExpTime:                getEnvInt("OAUTH_CODE_EXP_TIME", 1),
+		TrustedProxies:                  getEnvList("TRUSTED_PROXIES"),
+		ProxyHeader:                     getEnv("PROXY_HEADER", "X-Forwarded-For"),
+	}
+
+	if len(config.TokenLookup) == 0 {
+		config.TokenLookup = []string{TokenLookupCookie, TokenLookupHeader}
+	}
+	if config.WebAuthnRPName == "" {
+		config.WebAuthnRPName = config.Issuer
+	}
+
+	return config, nil
+}
+
+// DeliverTokensInCookies reports whether issued tokens are set as cookies
+func (c *Config) DeliverTokensInCookies() bool {
+	return c.TokenDelivery != TokenDeliveryBody
+}
+
+// DeliverTokensInBody reports whether issued tokens are returned in the
+// response body
+func (c *Config) DeliverTokensInBody() bool {
+	return c.TokenDelivery == TokenDeliveryBody || c.TokenDelivery == TokenDeliveryBoth
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList splits a comma separated variable, ignoring empty items
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -1,244 +1,450 @@
-package fiber
-
-import (
-	"log"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/mohaali482/goAuth/auth"
-	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
-	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
-)
-
-func App(s auth.UserService) *fiber.App {
-	app := fiber.New()
-	app.Use(logger.New(logger.Config{
-		TimeFormat: time.RFC3339,
-		TimeZone:   "Africa/Addis_Ababa",
-		Format:     "[${time}] ${ip} ${latency} ${status} - ${method} ${path}\n",
-	}))
-	accountsGroup := app.Group("/accounts")
-	{
-		accountsGroup.Post("/login", Login(s))
-		accountsGroup.Post("/signup", Signup(s))
-		accountsGroup.Delete("/logout", Logout(s))
-		accountsGroup.Get("/refresh", RefreshToken(s))
-	}
-
-	usersGroup := app.Group("/users").Use(middlewares.AuthMiddleware(s))
-	{
-		usersGroup.Get("", GetAll(s))
-		usersGroup.Get("/:id", GetByID(s))
-		usersGroup.Delete("/:id", Delete(s))
-		usersGroup.Patch("/:id", Update(s))
-		usersGroup.Post("", Create(s))
-	}
-
-	return app
-}
-
-func Login(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Login started")
-		var userLogin auth.UserLogin
-		err := c.BodyParser(&userLogin)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to login. Error: ", err)
-			return c.Status(fiber.ErrUnprocessableEntity.Code).JSON(err)
-		}
-		user, err := s.Login(userLogin.Username, userLogin.Password)
-		if err != nil {
-			log.Default().Println("Error logging in. Error: ", err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrWrongCredentials.Error()})
-		}
-		tokens, err := s.GenerateJWT(user)
-		if err != nil {
-			log.Default().Println("Error generating tokens. Error: ", err)
-			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
-		}
-
-		c.Cookie(&fiber.Cookie{Name: "refresh_token", Value: tokens["refresh"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
-		c.Cookie(&fiber.Cookie{Name: "access_token", Value: tokens["access"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
-
-		log.Default().Println("Login successful")
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
-	}
-}
-
-func Logout(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Logout started")
-		c.Cookie(&fiber.Cookie{Name: "refresh_token", Value: "", Expires: time.Now().Add(-time.Hour), HTTPOnly: true})
-		c.Cookie(&fiber.Cookie{Name: "access_token", Value: "", Expires: time.Now().Add(-time.Hour), HTTPOnly: true})
-		log.Default().Println("Logout successful")
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
-	}
-}
-
-func Signup(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Signup started")
-		var userForm auth.UserForm
-		err := c.BodyParser(&userForm)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to create user. Error: ", err)
-			return c.Status(fiber.ErrUnprocessableEntity.Code).JSON(err)
-		}
-		err = userForm.Validate()
-		if err != nil {
-			log.Default().Println("Error validating user while trying to create user. Error: ", err)
-			return errors.ReturnErrorResponse(err, c)
-		}
-
-		user, err := s.Create(userForm.ToUserEntity())
-		if err != nil {
-			log.Default().Println("Error creating user while trying to create user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
-		log.Default().Println("User created successfully")
-		return c.Status(fiber.StatusCreated).JSON(user)
-	}
-
-}
-
-func RefreshToken(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		refreshToken := c.Cookies("refresh_token", "")
-		if refreshToken != "" {
-			log.Default().Println("Error getting refresh_token while trying to refresh token.")
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refresh_token not found in cookie"})
-		}
-
-		tokens, err := s.RefreshToken(refreshToken)
-		if err != nil {
-			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
-			return c.Status(fiber.StatusUnprocessableEntity).JSON(err)
-		}
-		c.Cookie(&fiber.Cookie{Name: "refresh_token", Value: tokens["refresh"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
-		c.Cookie(&fiber.Cookie{Name: "access_token", Value: tokens["access"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
-		log.Default().Println("Token refreshed successfully")
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
-	}
-}
-
-func Create(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Creating user started")
-		var user auth.User
-		err := c.BodyParser(&user)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to create user. Error: ", err)
-			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
-		}
-
-		err = user.Validate()
-		if err != nil {
-			log.Default().Println("Error validating user while trying to create user. Error: ", err)
-			return errors.ReturnErrorResponse(err, c)
-		}
-
-		user, err = s.Create(user)
-		if err != nil {
-			log.Default().Println("Error creating user while trying to create user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
-		log.Default().Println("User created successfully")
-		return c.Status(fiber.StatusCreated).JSON(user)
-	}
-
-}
-
-func Delete(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Deleting user started")
-		id, err := strconv.Atoi(c.Params("id", ""))
-		if err != nil {
-			log.Default().Println("Error converting id while trying to delete user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
-		}
-		_, err = s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id while trying to delete user. Error: ", err)
-			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-		}
-		if err := s.Delete(id); err != nil {
-			log.Default().Println("Error deleting user while trying to delete user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
-		log.Default().Println("User deleted successfully")
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
-	}
-}
-
-func GetAll(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Getting all users started")
-		users, err := s.GetAll()
-		if err != nil {
-			log.Default().Println("Error getting all users. Error: ", err)
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
-		log.Default().Println("Users fetched successfully")
-		return c.Status(http.StatusOK).JSON(users)
-	}
-}
-
-func GetByID(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Getting user by id started")
-		id, err := strconv.Atoi(c.Params("id", ""))
-		if err != nil {
-			log.Default().Println("Error converting id. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
-		}
-		user, err := s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id. Error: ", err)
-			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-		}
-		log.Default().Println("User fetched successfully")
-		return c.Status(fiber.StatusOK).JSON(user)
-	}
-}
-
-func Update(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		log.Default().Println("Updating user started")
-		var userForm auth.UserForm
-		id, err := strconv.Atoi(c.Params("id", ""))
-		if err != nil {
-			log.Default().Println("Error converting id while trying to update user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
-		}
-		_, err = s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id while trying to update user. Error: ", err)
-			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-		}
-		err = c.BodyParser(&userForm)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to update user. Error: ", err)
-			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
-		}
-
-		if userForm.Phone != "" {
-			err = userForm.ValidatePhone()
-			if err != nil {
-				log.Default().Println("Error validating phone while trying to update user. Error: ", err)
-				return errors.ReturnErrorResponse(err, c)
-			}
-		}
-
-		user, err := s.Update(id, userForm.ToUserEntity())
-		if err != nil {
-			log.Default().Println("Error updating user while trying to update user. Error: ", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-		}
-
-		log.Default().Println("User updated successfully")
-		return c.Status(fiber.StatusOK).JSON(user)
-	}
-}
+package fiber
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+func App(s auth.UserService) *fiber.App {
+	// The client IP used to throttle logins is only read from ProxyHeader
+	// when the request comes from one of the trusted proxies
+	fiberConfig := fiber.Config{}
+	if len(s.Config.TrustedProxies) > 0 {
+		fiberConfig = fiber.Config{
+			ProxyHeader:             s.Config.ProxyHeader,
+			EnableTrustedProxyCheck: true,
+			TrustedProxies:          s.Config.TrustedProxies,
+			EnableIPValidation:      true,
+		}
+	}
+	app := fiber.New(fiberConfig)
+	app.Use(logger.New(logger.Config{
+		TimeFormat: time.RFC3339,
+		TimeZone:   "Africa/Addis_Ababa",
+		Format:     "[${time}] ${ip} ${latency} ${status} - ${method} ${path}\n",
+	}))
+	app.Get("/.well-known/jwks.json", JWKS(s))
+	accountsGroup := app.Group("/accounts")
+	{
+		accountsGroup.Post("/login", Login(s))
+		accountsGroup.Post("/login/code", RequestLoginCode(s))
+		accountsGroup.Post("/login/code/verify", LoginWithCode(s))
+		accountsGroup.Post("/signup", Signup(s))
+		accountsGroup.Delete("/logout", Logout(s))
+		accountsGroup.Delete("/logout/all", middlewares.AuthMiddleware(s), LogoutAll(s))
+		accountsGroup.Get("/refresh", RefreshToken(s))
+		accountsGroup.Get("/me", middlewares.ScopedAuthMiddleware(s, auth.ScopeProfile), GetMe(s))
+		accountsGroup.Patch("/me", middlewares.AuthMiddleware(s), UpdateMe(s))
+		accountsGroup.Delete("/me", middlewares.AuthMiddleware(s), DeleteMe(s))
+		accountsGroup.Post("/password", middlewares.AuthMiddleware(s), ChangePassword(s))
+		accountsGroup.Post("/password/forgot", ForgotPassword(s))
+		accountsGroup.Post("/password/reset", ResetPassword(s))
+		accountsGroup.Post("/mfa/verify", VerifyMFA(s))
+		accountsGroup.Post("/mfa/totp", middlewares.AuthMiddleware(s), EnrollTOTP(s))
+		accountsGroup.Post("/mfa/totp/confirm", middlewares.AuthMiddleware(s), ConfirmTOTP(s))
+		accountsGroup.Delete("/mfa", middlewares.AuthMiddleware(s), DisableMFA(s))
+		accountsGroup.Post("/phone/verification", SendPhoneVerification(s))
+		accountsGroup.Post("/phone/verify", VerifyPhone(s))
+		accountsGroup.Post("/email/verification", middlewares.AuthMiddleware(s), SendEmailVerification(s))
+		accountsGroup.Post("/email/verify", VerifyEmail(s))
+		accountsGroup.Post("/webauthn/register/begin", middlewares.AuthMiddleware(s), BeginWebAuthnRegistration(s))
+		accountsGroup.Post("/webauthn/register/finish", middlewares.AuthMiddleware(s), FinishWebAuthnRegistration(s))
+		accountsGroup.Post("/webauthn/login/begin", BeginWebAuthnLogin(s))
+		accountsGroup.Post("/webauthn/login/finish", FinishWebAuthnLogin(s))
+		accountsGroup.Post("/webauthn/mfa/begin", BeginWebAuthnMFA(s))
+		accountsGroup.Post("/webauthn/mfa/finish", FinishWebAuthnMFA(s))
+		accountsGroup.Get("/webauthn/credentials", middlewares.AuthMiddleware(s), GetWebAuthnCredentials(s))
+		accountsGroup.Delete("/webauthn/credentials/:id", middlewares.AuthMiddleware(s), DeleteWebAuthnCredential(s))
+		accountsGroup.Get("/sessions", middlewares.AuthMiddleware(s), GetSessions(s))
+		accountsGroup.Delete("/sessions/:id", middlewares.AuthMiddleware(s), RevokeSession(s))
+	}
+
+	usersGroup := app.Group("/users").Use(middlewares.AuthMiddleware(s))
+	{
+		usersGroup.Get("", middlewares.RequirePermission(s, auth.PermissionReadUsers), GetAll(s))
+		usersGroup.Get("/:id", middlewares.RequireSelfOrPermission(s, auth.PermissionReadSelf, auth.PermissionReadUsers), GetByID(s))
+		usersGroup.Delete("/:id", middlewares.RequirePermission(s, auth.PermissionDeleteUsers), Delete(s))
+		usersGroup.Post("/:id/unlock", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), UnlockLogin(s))
+		usersGroup.Post("/:id/activate", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), Activate(s))
+		usersGroup.Post("/:id/deactivate", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), Deactivate(s))
+		usersGroup.Get("/:id/sessions", middlewares.RequirePermission(s, auth.PermissionReadUsers), GetUserSessions(s))
+		usersGroup.Delete("/:id/sessions/:session_id", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), RevokeUserSession(s))
+		usersGroup.Patch("/:id", middlewares.RequireSelfOrPermission(s, auth.PermissionUpdateSelf, auth.PermissionUpdateUsers), Update(s))
+		usersGroup.Post("", middlewares.RequirePermission(s, auth.PermissionCreateUsers), Create(s))
+	}
+
+	adminGroup := app.Group("/admin").Use(middlewares.AuthMiddleware(s), middlewares.RequireRole(s, auth.RoleAdmin))
+	{
+		adminGroup.Post("/keys/rotate", middlewares.RequirePermission(s, auth.PermissionRotateKeys), RotateSigningKey(s))
+		adminGroup.Post("/oauth/clients", middlewares.RequirePermission(s, auth.PermissionManageClients), RegisterOAuthClient(s))
+		adminGroup.Get("/oauth/clients", middlewares.RequirePermission(s, auth.PermissionManageClients), GetOAuthClients(s))
+		adminGroup.Delete("/oauth/clients/:id", middlewares.RequirePermission(s, auth.PermissionManageClients), DeleteOAuthClient(s))
+	}
+
+	oauthGroup := app.Group("/oauth")
+	{
+		oauthGroup.Post("/introspect", Introspect(s))
+		oauthGroup.Get("/authorize", middlewares.AuthMiddleware(s), Authorize(s))
+		oauthGroup.Post("/authorize", middlewares.AuthMiddleware(s), Consent(s))
+		oauthGroup.Post("/token", Token(s))
+	}
+
+	return app
+}
+
+func Login(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Login started")
+		var userLogin auth.UserLogin
+		err := c.BodyParser(&userLogin)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to login. Error: ", err)
+			return c.Status(fiber.ErrUnprocessableEntity.Code).JSON(err)
+		}
+		user, err := s.Login(userLogin.Username, userLogin.Password, c.IP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error logging in. Error: ", err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error logging in. Error: ", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrWrongCredentials.Error()})
+		}
+		mfaRequired, err := s.MFARequired(user)
+		if err != nil {
+			log.Default().Println("Error checking mfa while trying to login. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if mfaRequired {
+			log.Default().Println("Login waiting for mfa code")
+			return respondWithMFAToken(c, s, user)
+		}
+		tokens, err := s.GenerateJWT(user, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Login successful")
+		return respondWithTokens(c, s, tokens)
+	}
+}
+
+func Logout(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Logout started")
+		accessToken := middlewares.AccessToken(c, s.Config)
+		refreshToken := requestRefreshToken(c, s)
+		if err := s.Logout(accessToken, refreshToken); err != nil {
+			log.Default().Println("Error revoking tokens while trying to logout. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		clearTokenCookies(c)
+		log.Default().Println("Logout successful")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func LogoutAll(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Logout from all sessions started")
+		principal, _ := middlewares.Principal(c)
+		if err := s.LogoutAll(principal.Claims.ID); err != nil {
+			log.Default().Println("Error revoking tokens while trying to logout from all sessions. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		clearTokenCookies(c)
+		log.Default().Println("Logout from all sessions successful")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func Signup(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Signup started")
+		var userForm auth.UserForm
+		err := c.BodyParser(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to create user. Error: ", err)
+			return c.Status(fiber.ErrUnprocessableEntity.Code).JSON(err)
+		}
+		err = userForm.Validate()
+		if err != nil {
+			log.Default().Println("Error validating user while trying to create user. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err := s.Signup(userForm.ToUserEntity())
+		if err != nil {
+			log.Default().Println("Error creating user while trying to create user. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+		log.Default().Println("Signup finished")
+		if s.Config.EnumerationSafeSignup {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "signup received, you can now log in to your account"})
+		}
+		return c.Status(fiber.StatusCreated).JSON(user)
+	}
+
+}
+
+func RefreshToken(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		refreshToken := requestRefreshToken(c, s)
+		if refreshToken == "" {
+			log.Default().Println("Error getting refresh_token while trying to refresh token.")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refresh_token not found in request"})
+		}
+
+		tokens, err := s.RefreshToken(refreshToken, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err == auth.ErrRefreshTokenReused {
+			log.Default().Println("Refresh token reuse detected, token family revoked.")
+			clearTokenCookies(c)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive {
+			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
+			clearTokenCookies(c)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(err)
+		}
+		log.Default().Println("Token refreshed successfully")
+		return respondWithTokens(c, s, tokens)
+	}
+}
+
+func Create(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Creating user started")
+		var user auth.User
+		err := c.BodyParser(&user)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to create user. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		err = user.Validate()
+		if err != nil {
+			log.Default().Println("Error validating user while trying to create user. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err = s.Create(user)
+		if err != nil {
+			log.Default().Println("Error creating user while trying to create user. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+		log.Default().Println("User created successfully")
+		return c.Status(fiber.StatusCreated).JSON(user)
+	}
+
+}
+
+func Delete(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Deleting user started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to delete user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to delete user. Error: ", err)
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		if err := s.Delete(id); err != nil {
+			log.Default().Println("Error deleting user while trying to delete user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User deleted successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func UnlockLogin(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Unlocking user login started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to unlock user login. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to unlock user login. Error: ", err)
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		if err := s.UnlockLogin(id); err != nil {
+			log.Default().Println("Error unlocking user login. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User login unlocked successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func Activate(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Activating user started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to activate user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		if err := s.Activate(id); err != nil {
+			log.Default().Println("Error activating user. Error: ", err)
+			if err == auth.ErrUserNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User activated successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func Deactivate(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Deactivating user started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to deactivate user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		if err := s.Deactivate(id); err != nil {
+			log.Default().Println("Error deactivating user. Error: ", err)
+			if err == auth.ErrUserNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User deactivated successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func GetAll(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting all users started")
+		users, err := s.GetAll()
+		if err != nil {
+			log.Default().Println("Error getting all users. Error: ", err)
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Users fetched successfully")
+		return c.Status(http.StatusOK).JSON(users)
+	}
+}
+
+func GetByID(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting user by id started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		user, err := s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id. Error: ", err)
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		log.Default().Println("User fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(user)
+	}
+}
+
+func Update(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Updating user started")
+		var userForm auth.UserForm
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to update user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to update user. Error: ", err)
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		err = c.BodyParser(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to update user. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if userForm.Phone != "" {
+			err = userForm.ValidatePhone()
+			if err != nil {
+				log.Default().Println("Error validating phone while trying to update user. Error: ", err)
+				return errors.ReturnErrorResponse(err, c)
+			}
+		}
+		if userForm.Email != "" {
+			err = userForm.ValidateEmail()
+			if err != nil {
+				log.Default().Println("Error validating email while trying to update user. Error: ", err)
+				return errors.ReturnErrorResponse(err, c)
+			}
+		}
+
+		// Users editing themselves go through UpdateSelf so the password can
+		// only be changed with the current one
+		var user auth.User
+		if principal, _ := middlewares.Principal(c); principal.Claims.ID == id {
+			user, err = s.UpdateSelf(id, userForm.ToUserEntity(), userForm.CurrentPassword)
+		} else {
+			user, err = s.Update(id, userForm.ToUserEntity())
+		}
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to update user. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error updating user while trying to update user. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		log.Default().Println("User updated successfully")
+		return c.Status(fiber.StatusOK).JSON(user)
+	}
+}
+
+func JWKS(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.Status(fiber.StatusOK).JSON(s.JWKS())
+	}
+}
+
+func RotateSigningKey(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Signing key rotation started")
+		key, err := s.RotateSigningKey()
+		if err != nil {
+			log.Default().Println("Error rotating signing key. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Signing key rotated successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"kid": key.ID})
+	}
+}
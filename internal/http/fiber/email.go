@@ -0,0 +1,61 @@
+package fiber
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+func SendEmailVerification(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Email verification started")
+		principal, _ := middlewares.Principal(c)
+		err := s.SendEmailVerification(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error sending email verification. Error: ", err)
+			return c.Status(emailErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Email verification finished")
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "a verification link has been sent to your email address"})
+	}
+}
+
+func VerifyEmail(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Verifying email started")
+		var form auth.VerifyEmailForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify email. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify email. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.VerifyEmail(form.Token)
+		if err != nil {
+			log.Default().Println("Error verifying email. Error: ", err)
+			return c.Status(emailErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Email verified successfully")
+		return c.JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func emailErrorStatus(err error) int {
+	switch err {
+	case auth.ErrEmailVerificationDisabled:
+		return fiber.StatusNotImplemented
+	case auth.ErrEmailAlreadyVerified:
+		return fiber.StatusConflict
+	case auth.ErrNoEmail, auth.ErrInvalidToken:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,112 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+func GetMe(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting current user started")
+		principal, _ := middlewares.Principal(c)
+		user, err := s.GetByID(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error getting current user. Error: ", err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		log.Default().Println("Current user fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(user.Profile())
+	}
+}
+
+func UpdateMe(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Updating current user started")
+		principal, _ := middlewares.Principal(c)
+		var userForm auth.UserForm
+		err := c.BodyParser(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to update current user. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if userForm.Phone != "" {
+			err = userForm.ValidatePhone()
+			if err != nil {
+				log.Default().Println("Error validating phone while trying to update current user. Error: ", err)
+				return errors.ReturnErrorResponse(err, c)
+			}
+		}
+		if userForm.Email != "" {
+			err = userForm.ValidateEmail()
+			if err != nil {
+				log.Default().Println("Error validating email while trying to update current user. Error: ", err)
+				return errors.ReturnErrorResponse(err, c)
+			}
+		}
+
+		user, err := s.UpdateSelf(principal.Claims.ID, userForm.ToUserEntity(), userForm.CurrentPassword)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to update current user. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error updating current user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Current user updated successfully")
+		return c.Status(fiber.StatusOK).JSON(user.Profile())
+	}
+}
+
+func DeleteMe(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Deleting current user started")
+		principal, _ := middlewares.Principal(c)
+		if err := s.DeleteSelf(principal.Claims.ID); err != nil {
+			log.Default().Println("Error deleting current user. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		clearTokenCookies(c)
+		log.Default().Println("Current user deleted successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func ChangePassword(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Changing password started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.PasswordChangeForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to change password. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to change password. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.ChangePassword(principal.Claims, form.CurrentPassword, form.NewPassword)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to change password. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error changing password. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+		log.Default().Println("Password changed successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
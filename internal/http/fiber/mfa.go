@@ -0,0 +1,145 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+// respondWithMFAToken answers a password or code login of a user with
+// two-factor authentication enabled. Tokens are issued by VerifyMFA.
+func respondWithMFAToken(c *fiber.Ctx, s auth.UserService, user auth.User) error {
+	token, err := s.GenerateMFAToken(user)
+	if err != nil {
+		log.Default().Println("Error generating mfa token. Error: ", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "mfa required", "mfa_token": token})
+}
+
+func VerifyMFA(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Verifying mfa code started")
+		var form auth.MFAVerifyForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify mfa code. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify mfa code. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err := s.VerifyMFA(form.MFAToken, form.Code)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Mfa verification throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error verifying mfa code. Error: ", err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error verifying mfa code. Error: ", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrInvalidMFACode.Error()})
+		}
+		tokens, err := s.GenerateJWT(user, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Mfa code verified successfully")
+		return respondWithTokens(c, s, tokens)
+	}
+}
+
+func EnrollTOTP(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Enrolling totp started")
+		principal, _ := middlewares.Principal(c)
+		enrollment, err := s.EnrollTOTP(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error enrolling totp. Error: ", err)
+			return c.Status(mfaErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Totp enrolled successfully")
+		return c.Status(fiber.StatusOK).JSON(enrollment)
+	}
+}
+
+func ConfirmTOTP(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Confirming totp started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.MFACodeForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to confirm totp. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to confirm totp. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		codes, err := s.ConfirmTOTP(principal.Claims.ID, form.Code)
+		if err != nil {
+			log.Default().Println("Error confirming totp. Error: ", err)
+			return c.Status(mfaErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Totp confirmed successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success", "recovery_codes": codes})
+	}
+}
+
+func DisableMFA(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Disabling mfa started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.MFADisableForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to disable mfa. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to disable mfa. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.DisableMFA(principal.Claims, form.Password, form.Code)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Disabling mfa throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error disabling mfa. Error: ", err)
+			return c.Status(mfaErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Mfa disabled successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func mfaErrorStatus(err error) int {
+	switch err {
+	case auth.ErrMFADisabled:
+		return fiber.StatusNotImplemented
+	case auth.ErrMFAAlreadyEnabled:
+		return fiber.StatusConflict
+	case auth.ErrMFANotEnrolled, auth.ErrInvalidMFACode, auth.ErrWrongCredentials:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
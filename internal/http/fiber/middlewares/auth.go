@@ -1,24 +1,75 @@
-package middlewares
-
-import (
-	"github.com/gofiber/fiber/v2"
-	"github.com/mohaali482/goAuth/auth"
-)
-
-func AuthMiddleware(s auth.UserService) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		tokenString := c.Cookies("access_token", "")
-		if tokenString == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "request does not contain an access token"})
-		}
-
-		_, err := s.ValidateJWT(tokenString)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "access token is not valid"})
-		}
-
-		return c.Next()
-
-	}
-
-}
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/config"
+)
+
+// AuthMiddleware authenticates first party callers. Access tokens issued to
+// OAuth clients are rejected.
+func AuthMiddleware(s auth.UserService) fiber.Handler {
+	return ScopedAuthMiddleware(s, "")
+}
+
+// ScopedAuthMiddleware authenticates first party callers and OAuth clients
+// that were granted the scope
+func ScopedAuthMiddleware(s auth.UserService, scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := AccessToken(c, s.Config)
+		if tokenString == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "request does not contain an access token"})
+		}
+
+		principal, err := s.Principal(tokenString)
+		if err == auth.ErrUserInactive {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "access token is not valid"})
+		}
+		if !principal.Claims.AllowsScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": auth.ErrInsufficientScope.Error()})
+		}
+
+		c.Locals(auth.PrincipalKey, principal)
+		c.SetUserContext(auth.ContextWithPrincipal(c.UserContext(), principal))
+		return c.Next()
+
+	}
+
+}
+
+// Principal returns the caller authenticated by AuthMiddleware
+func Principal(c *fiber.Ctx) (auth.Principal, bool) {
+	return auth.PrincipalFromContext(c.UserContext())
+}
+
+// AccessToken returns the access token of the request looking it up in the
+// places configured in Config.TokenLookup, in order
+func AccessToken(c *fiber.Ctx, cfg *config.Config) string {
+	return lookupToken(c, cfg, "access_token", true)
+}
+
+// RefreshToken returns the refresh token cookie of the request when cookies
+// are part of Config.TokenLookup. The Authorization header carries the access
+// token and is never read for the refresh token.
+func RefreshToken(c *fiber.Ctx, cfg *config.Config) string {
+	return lookupToken(c, cfg, "refresh_token", false)
+}
+
+func lookupToken(c *fiber.Ctx, cfg *config.Config, cookie string, header bool) string {
+	for _, source := range cfg.TokenLookup {
+		switch source {
+		case config.TokenLookupHeader:
+			if token := auth.BearerToken(c.Get(fiber.HeaderAuthorization)); header && token != "" {
+				return token
+			}
+		case config.TokenLookupCookie:
+			if token := c.Cookies(cookie, ""); token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+)
+
+// RequireRole only lets callers with one of the roles through. It must run
+// after AuthMiddleware.
+func RequireRole(s auth.UserService, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := Principal(c)
+		if !ok || !s.HasRole(principal.Claims, roles...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": auth.ErrForbidden.Error()})
+		}
+		return c.Next()
+	}
+}
+
+// RequirePermission only lets callers whose role grants one of the
+// permissions through. It must run after AuthMiddleware.
+func RequirePermission(s auth.UserService, permissions ...auth.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := Principal(c)
+		if !ok || !hasAnyPermission(s, principal, permissions) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": auth.ErrForbidden.Error()})
+		}
+		return c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets callers with selfPermission act on the user
+// identified by the :id route parameter when it is themselves, and callers
+// with permission act on any user. It must run after AuthMiddleware.
+func RequireSelfOrPermission(s auth.UserService, selfPermission auth.Permission, permission auth.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := Principal(c)
+		if ok && s.HasPermission(principal.Claims, permission) {
+			return c.Next()
+		}
+		if ok && c.Params("id") == strconv.Itoa(principal.Claims.ID) && s.HasPermission(principal.Claims, selfPermission) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": auth.ErrForbidden.Error()})
+	}
+}
+
+func hasAnyPermission(s auth.UserService, principal auth.Principal, permissions []auth.Permission) bool {
+	for _, permission := range permissions {
+		if s.HasPermission(principal.Claims, permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package fiber
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+// Introspect answers token introspection requests of protected resources as
+// defined by RFC 7662
+func Introspect(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Token introspection started")
+		clientID, clientSecret, _ := auth.BasicCredentials(c.Get(fiber.HeaderAuthorization))
+		err := s.AuthenticateIntrospectionClient(clientID, clientSecret)
+		if err == auth.ErrIntrospectionDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error authenticating client while trying to introspect token. Error: ", err)
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="introspection"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_client"})
+		}
+		token := c.FormValue("token")
+		if token == "" {
+			log.Default().Println("Error getting token while trying to introspect token.")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request"})
+		}
+
+		response := s.Introspect(token, c.FormValue("token_type_hint"))
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		log.Default().Println("Token introspection finished")
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// Authorize validates an authorization request of a client and returns what
+// the consent step shows the logged in user
+func Authorize(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Authorization request started")
+		request := authorizationRequest(c)
+		client, err := s.ValidateAuthorizationRequest(request)
+		if err != nil {
+			log.Default().Println("Error validating authorization request. Error: ", err)
+			return respondWithAuthorizationError(c, s, request, err)
+		}
+		scope, _ := auth.ParseScope(request.Scope)
+		log.Default().Println("Authorization request validated successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"client_id":    client.ID,
+			"client_name":  client.Name,
+			"redirect_uri": request.RedirectURI,
+			"scope":        scope,
+		})
+	}
+}
+
+// Consent records the answer of the logged in user to an authorization
+// request and returns where to redirect the user
+func Consent(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Authorization consent started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.AuthorizationConsentForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to record authorization consent. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		var redirectTo string
+		if form.Approve {
+			redirectTo, err = s.Authorize(principal.Claims.ID, form.AuthorizationRequest)
+		} else {
+			redirectTo, err = s.DenyAuthorization(form.AuthorizationRequest)
+		}
+		if err != nil {
+			log.Default().Println("Error recording authorization consent. Error: ", err)
+			return respondWithAuthorizationError(c, s, form.AuthorizationRequest, err)
+		}
+		log.Default().Println("Authorization consent recorded successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"redirect_to": redirectTo})
+	}
+}
+
+// Token is the OAuth 2.0 token endpoint. Clients authenticate with http
+// basic auth or the client_id and client_secret parameters.
+func Token(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Token request started")
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		c.Set(fiber.HeaderPragma, "no-cache")
+		clientID, clientSecret, basic := auth.BasicCredentials(c.Get(fiber.HeaderAuthorization))
+		if !basic {
+			clientID, clientSecret = c.FormValue("client_id"), c.FormValue("client_secret")
+		}
+		client, err := s.AuthenticateOAuthClient(clientID, clientSecret)
+		if err != nil {
+			log.Default().Println("Error authenticating client while trying to grant token. Error: ", err)
+			if basic {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+			}
+			return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": auth.OAuthErrorCode(err), "error_description": err.Error()})
+		}
+
+		response, err := s.GrantToken(client, auth.TokenRequest{
+			GrantType:    c.FormValue("grant_type"),
+			Code:         c.FormValue("code"),
+			RedirectURI:  c.FormValue("redirect_uri"),
+			CodeVerifier: c.FormValue("code_verifier"),
+			RefreshToken: c.FormValue("refresh_token"),
+		}, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error granting token. Error: ", err)
+			return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": auth.OAuthErrorCode(err), "error_description": err.Error()})
+		}
+		log.Default().Println("Token granted successfully")
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func RegisterOAuthClient(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Registering oauth client started")
+		var form auth.OAuthClientForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to register oauth client. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to register oauth client. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		client, err := s.RegisterOAuthClient(form)
+		if err != nil {
+			log.Default().Println("Error registering oauth client. Error: ", err)
+			return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Oauth client registered successfully")
+		return c.Status(fiber.StatusCreated).JSON(client)
+	}
+}
+
+func GetOAuthClients(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting oauth clients started")
+		clients, err := s.GetOAuthClients()
+		if err != nil {
+			log.Default().Println("Error getting oauth clients. Error: ", err)
+			return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Oauth clients fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(clients)
+	}
+}
+
+func DeleteOAuthClient(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Deleting oauth client started")
+		err := s.DeleteOAuthClient(c.Params("id"))
+		if err == auth.ErrInvalidClient {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "client not found"})
+		}
+		if err != nil {
+			log.Default().Println("Error deleting oauth client. Error: ", err)
+			return c.Status(oauthErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Oauth client deleted successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func authorizationRequest(c *fiber.Ctx) auth.AuthorizationRequest {
+	return auth.AuthorizationRequest{
+		ResponseType:        c.Query("response_type"),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+	}
+}
+
+// respondWithAuthorizationError reports an invalid authorization request.
+// Unless the client or the redirect uri is invalid, the response also tells
+// where to redirect the user to report the error to the client.
+func respondWithAuthorizationError(c *fiber.Ctx, s auth.UserService, r auth.AuthorizationRequest, err error) error {
+	response := fiber.Map{"error": auth.OAuthErrorCode(err), "error_description": err.Error()}
+	switch err {
+	case auth.ErrOAuthDisabled, auth.ErrInvalidClient, auth.ErrInvalidRedirectURI:
+	default:
+		if oauthErrorStatus(err) == fiber.StatusBadRequest {
+			response["redirect_to"] = s.AuthorizationErrorRedirect(r, err)
+		}
+	}
+	status := oauthErrorStatus(err)
+	if err == auth.ErrInvalidClient {
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(response)
+}
+
+func oauthErrorStatus(err error) int {
+	switch auth.OAuthErrorCode(err) {
+	case "invalid_client":
+		return fiber.StatusUnauthorized
+	case "temporarily_unavailable":
+		return fiber.StatusNotImplemented
+	case "server_error":
+		return fiber.StatusInternalServerError
+	}
+	return fiber.StatusBadRequest
+}
@@ -0,0 +1,67 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+)
+
+func ForgotPassword(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Forgot password started")
+		var form auth.ForgotPasswordForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to start password reset. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to start password reset. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.ForgotPassword(form.Identifier, c.IP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password reset throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrPasswordResetDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error starting password reset. Error: ", err)
+		}
+		log.Default().Println("Forgot password finished")
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "if the account exists, password reset instructions have been sent"})
+	}
+}
+
+func ResetPassword(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Password reset started")
+		var form auth.ResetPasswordForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to reset password. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to reset password. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.ResetPassword(form.Token, form.Password)
+		if err != nil {
+			log.Default().Println("Error resetting password. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+		log.Default().Println("Password reset successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
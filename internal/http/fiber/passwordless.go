@@ -0,0 +1,94 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+)
+
+func RequestLoginCode(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Login code request started")
+		var form auth.LoginCodeRequestForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to request login code. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to request login code. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.RequestLoginCode(form.Identifier, c.IP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login code request throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrPasswordlessDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error requesting login code. Error: ", err)
+		}
+		log.Default().Println("Login code request finished")
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "if the account exists, a login code has been sent"})
+	}
+}
+
+func LoginWithCode(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Login with code started")
+		var form auth.LoginCodeForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to login with code. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to login with code. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err := s.LoginWithCode(form.Identifier, form.Code, c.IP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login with code throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrPasswordlessDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error logging in with code. Error: ", err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error logging in with code. Error: ", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrInvalidLoginCode.Error()})
+		}
+		mfaRequired, err := s.MFARequired(user)
+		if err != nil {
+			log.Default().Println("Error checking mfa while trying to login with code. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if mfaRequired {
+			log.Default().Println("Login with code waiting for mfa code")
+			return respondWithMFAToken(c, s, user)
+		}
+		tokens, err := s.GenerateJWT(user, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Login with code successful")
+		return respondWithTokens(c, s, tokens)
+	}
+}
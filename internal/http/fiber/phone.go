@@ -0,0 +1,68 @@
+package fiber
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+)
+
+func SendPhoneVerification(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Phone verification started")
+		var form auth.PhoneVerificationRequestForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to send phone verification. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to send phone verification. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.SendPhoneVerification(form.Phone)
+		if err == auth.ErrPhoneVerificationDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error sending phone verification. Error: ", err)
+		}
+		log.Default().Println("Phone verification finished")
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "if the phone number needs verification, a code has been sent"})
+	}
+}
+
+func VerifyPhone(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Verifying phone started")
+		var form auth.PhoneVerificationForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify phone. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify phone. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		err = s.VerifyPhone(form.Phone, form.Code)
+		if err == auth.ErrPhoneVerificationDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrInvalidVerificationCode {
+			log.Default().Println("Error verifying phone. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error verifying phone. Error: ", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Phone verified successfully")
+		return c.JSON(fiber.Map{"message": "success"})
+	}
+}
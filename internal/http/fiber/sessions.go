@@ -0,0 +1,92 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+func GetSessions(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting sessions started")
+		principal, _ := middlewares.Principal(c)
+		sessions, err := s.GetSessions(principal.Claims.ID, principal.Claims.Family)
+		if err != nil {
+			log.Default().Println("Error getting sessions. Error: ", err)
+			return c.Status(sessionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Sessions fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(sessions)
+	}
+}
+
+func RevokeSession(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Revoking session started")
+		principal, _ := middlewares.Principal(c)
+		err := s.RevokeSession(principal.Claims.ID, c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error revoking session. Error: ", err)
+			return c.Status(sessionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		if c.Params("id", "") == principal.Claims.Family {
+			clearTokenCookies(c)
+		}
+		log.Default().Println("Session revoked successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func GetUserSessions(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting user sessions started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to get user sessions. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to get user sessions. Error: ", err)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		sessions, err := s.GetSessions(id, "")
+		if err != nil {
+			log.Default().Println("Error getting user sessions. Error: ", err)
+			return c.Status(sessionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User sessions fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(sessions)
+	}
+}
+
+func RevokeUserSession(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Revoking user session started")
+		id, err := strconv.Atoi(c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to revoke user session. Error: ", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id is not a valid id"})
+		}
+		err = s.RevokeSession(id, c.Params("session_id", ""))
+		if err != nil {
+			log.Default().Println("Error revoking user session. Error: ", err)
+			return c.Status(sessionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("User session revoked successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func sessionErrorStatus(err error) int {
+	switch err {
+	case auth.ErrSessionsDisabled:
+		return fiber.StatusNotImplemented
+	case auth.ErrSessionNotFound:
+		return fiber.StatusNotFound
+	}
+	return fiber.StatusInternalServerError
+}
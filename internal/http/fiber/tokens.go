@@ -0,0 +1,44 @@
+package fiber
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+type refreshTokenForm struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// respondWithTokens delivers an issued token pair as cookies, in the
+// response body or both depending on Config.TokenDelivery
+func respondWithTokens(c *fiber.Ctx, s auth.UserService, tokens map[string]string) error {
+	if s.Config.DeliverTokensInCookies() {
+		c.Cookie(&fiber.Cookie{Name: "refresh_token", Value: tokens["refresh"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
+		c.Cookie(&fiber.Cookie{Name: "access_token", Value: tokens["access"], Expires: time.Now().Add(time.Hour * 24), HTTPOnly: true})
+	}
+	if s.Config.DeliverTokensInBody() {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success", "access": tokens["access"], "refresh": tokens["refresh"]})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+}
+
+func clearTokenCookies(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{Name: "refresh_token", Value: "", Expires: time.Now().Add(-time.Hour), HTTPOnly: true})
+	c.Cookie(&fiber.Cookie{Name: "access_token", Value: "", Expires: time.Now().Add(-time.Hour), HTTPOnly: true})
+}
+
+// requestRefreshToken looks up the refresh token in its cookie and falls back
+// to the refresh_token field of a JSON body
+func requestRefreshToken(c *fiber.Ctx, s auth.UserService) string {
+	if token := middlewares.RefreshToken(c, s.Config); token != "" {
+		return token
+	}
+	var form refreshTokenForm
+	if err := c.BodyParser(&form); err != nil {
+		return ""
+	}
+	return form.RefreshToken
+}
@@ -0,0 +1,214 @@
+package fiber
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/fiber/errors"
+	"github.com/mohaali482/goAuth/internal/http/fiber/middlewares"
+)
+
+func BeginWebAuthnRegistration(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Beginning webauthn registration started")
+		principal, _ := middlewares.Principal(c)
+		options, err := s.BeginWebAuthnRegistration(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error beginning webauthn registration. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn registration begun successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"publicKey": options})
+	}
+}
+
+func FinishWebAuthnRegistration(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Finishing webauthn registration started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.WebAuthnRegistrationForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn registration. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn registration. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		credential, err := s.FinishWebAuthnRegistration(principal.Claims.ID, form.Name, form.SecondFactor, form.Credential)
+		if err != nil {
+			log.Default().Println("Error finishing webauthn registration. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn registration finished successfully")
+		return c.Status(fiber.StatusCreated).JSON(credential)
+	}
+}
+
+func BeginWebAuthnLogin(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Beginning webauthn login started")
+		options, err := s.BeginWebAuthnLogin(c.IP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Webauthn login throttled. Error: ", err)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error beginning webauthn login. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn login begun successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"publicKey": options})
+	}
+}
+
+func FinishWebAuthnLogin(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Finishing webauthn login started")
+		var form auth.WebAuthnLoginForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn login. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn login. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err := s.FinishWebAuthnLogin(form.Credential, c.IP())
+		if err == auth.ErrWebAuthnDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error finishing webauthn login. Error: ", err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error finishing webauthn login. Error: ", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrInvalidWebAuthnResponse.Error()})
+		}
+		tokens, err := s.GenerateJWT(user, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Webauthn login finished successfully")
+		return respondWithTokens(c, s, tokens)
+	}
+}
+
+func BeginWebAuthnMFA(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Beginning webauthn mfa started")
+		var form auth.WebAuthnMFABeginForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to begin webauthn mfa. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to begin webauthn mfa. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		options, err := s.BeginWebAuthnMFA(form.MFAToken)
+		if err != nil {
+			log.Default().Println("Error beginning webauthn mfa. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn mfa begun successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"publicKey": options})
+	}
+}
+
+func FinishWebAuthnMFA(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Finishing webauthn mfa started")
+		var form auth.WebAuthnMFAForm
+		err := c.BodyParser(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn mfa. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn mfa. Error: ", err)
+			return errors.ReturnErrorResponse(err, c)
+		}
+
+		user, err := s.FinishWebAuthnMFA(form.MFAToken, form.Credential)
+		if err == auth.ErrWebAuthnDisabled {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error finishing webauthn mfa. Error: ", err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Default().Println("Error finishing webauthn mfa. Error: ", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrInvalidWebAuthnResponse.Error()})
+		}
+		tokens, err := s.GenerateJWT(user, c.Get(fiber.HeaderUserAgent), c.IP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		log.Default().Println("Webauthn mfa finished successfully")
+		return respondWithTokens(c, s, tokens)
+	}
+}
+
+func GetWebAuthnCredentials(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Getting webauthn credentials started")
+		principal, _ := middlewares.Principal(c)
+		credentials, err := s.GetWebAuthnCredentials(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error getting webauthn credentials. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn credentials fetched successfully")
+		return c.Status(fiber.StatusOK).JSON(credentials)
+	}
+}
+
+func DeleteWebAuthnCredential(s auth.UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Default().Println("Deleting webauthn credential started")
+		principal, _ := middlewares.Principal(c)
+		err := s.DeleteWebAuthnCredential(principal.Claims.ID, c.Params("id", ""))
+		if err != nil {
+			log.Default().Println("Error deleting webauthn credential. Error: ", err)
+			return c.Status(webAuthnErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Default().Println("Webauthn credential deleted successfully")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+	}
+}
+
+func webAuthnErrorStatus(err error) int {
+	switch err {
+	case auth.ErrWebAuthnDisabled:
+		return fiber.StatusNotImplemented
+	case auth.ErrWebAuthnCredentialExists:
+		return fiber.StatusConflict
+	case auth.ErrWebAuthnCredentialNotFound:
+		return fiber.StatusNotFound
+	case auth.ErrInvalidWebAuthnResponse, auth.ErrInvalidAttestation, auth.ErrUnsupportedKey, auth.ErrMFANotEnrolled:
+		return fiber.StatusBadRequest
+	case auth.ErrInvalidToken:
+		return fiber.StatusUnauthorized
+	}
+	return fiber.StatusInternalServerError
+}
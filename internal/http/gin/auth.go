@@ -1,258 +1,479 @@
-package gin
-
-import (
-	"log"
-	"net/http"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-	"github.com/mohaali482/goAuth/auth"
-	"github.com/mohaali482/goAuth/internal/http/gin/errors"
-	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
-)
-
-func Handlers(s auth.UserService) *gin.Engine {
-	r := gin.Default()
-	accountsGroup := r.Group("/accounts")
-	{
-		accountsGroup.Handle("POST", "/login", Login(s))
-		accountsGroup.Handle("DELETE", "/logout", Logout(s))
-		accountsGroup.Handle("POST", "/signup", Signup(s))
-		accountsGroup.Handle("POST", "/refresh", RefreshToken(s))
-	}
-	usersGroup := r.Group("/users").Use(middlewares.AuthMiddleware(s))
-	{
-		usersGroup.Handle("POST", "", Create(s))
-		usersGroup.Handle("GET", "", GetAll(s))
-		usersGroup.Handle("GET", ":id", GetByID(s))
-		usersGroup.Handle("DELETE", ":id", Delete(s))
-		usersGroup.Handle("PATCH", ":id", Update(s))
-	}
-
-	return r
-
-}
-
-func Create(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Creating user started")
-		var user auth.User
-		err := c.ShouldBindJSON(&user)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to create user. Error: ", err)
-			c.AbortWithStatus(http.StatusUnprocessableEntity)
-			return
-		}
-
-		err = user.Validate()
-		if err != nil {
-			log.Default().Println("Error validating user while trying to create user. Error: ", err)
-			errors.ReturnErrorResponse(err, c)
-			return
-		}
-
-		user, err = s.Create(user)
-		if err != nil {
-			log.Default().Println("Error creating user while trying to create user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusCreated, user)
-		log.Default().Println("User created successfully")
-	}
-
-}
-
-func Delete(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Deleting user started")
-		id, err := strconv.Atoi(c.Param("id"))
-		if err != nil {
-			log.Default().Println("Error converting id while trying to delete user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
-		}
-		_, err = s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id while trying to delete user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		if err := s.Delete(id); err != nil {
-			log.Default().Println("Error deleting user while trying to delete user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "success"})
-		log.Default().Println("User deleted successfully")
-	}
-}
-
-func GetAll(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Getting all users started")
-		users, err := s.GetAll()
-		if err != nil {
-			log.Default().Println("Error getting all users. Error: ", err)
-			c.AbortWithError(http.StatusBadRequest, err)
-			return
-		}
-		c.JSON(http.StatusOK, users)
-		log.Default().Println("Users fetched successfully")
-	}
-}
-
-func GetByID(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Getting user by id started")
-		id, err := strconv.Atoi(c.Param("id"))
-		if err != nil {
-			log.Default().Println("Error converting id. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
-			return
-		}
-		user, err := s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		c.JSON(http.StatusOK, user)
-		log.Default().Println("User fetched successfully")
-	}
-}
-
-func Login(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Login started")
-		var userLogin auth.UserLogin
-		err := c.ShouldBindJSON(&userLogin)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to login. Error: ", err)
-			c.AbortWithError(http.StatusUnprocessableEntity, err)
-			return
-		}
-		user, err := s.Login(userLogin.Username, userLogin.Password)
-		if err != nil {
-			log.Default().Println("Error logging in. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrWrongCredentials.Error()})
-			return
-		}
-		tokens, err := s.GenerateJWT(user)
-		if err != nil {
-			log.Default().Println("Error generating tokens. Error: ", err)
-			c.AbortWithError(http.StatusUnprocessableEntity, err)
-			return
-		}
-
-		c.SetCookie("refresh_token", tokens["refresh"], 3600, "/", "localhost", false, true)
-		c.SetCookie("access_token", tokens["access"], 3600, "/", "localhost", false, true)
-		c.JSON(http.StatusOK, gin.H{"message": "success"})
-		log.Default().Println("Login successful")
-	}
-}
-
-func Logout(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Logout started")
-		c.SetCookie("refresh_token", "", 0, "/", "localhost", false, true)
-		c.SetCookie("access_token", "", 0, "/", "localhost", false, true)
-		c.JSON(http.StatusOK, gin.H{"message": "success"})
-		log.Default().Println("Logout successful")
-	}
-}
-
-func Signup(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Signup started")
-		var userForm auth.UserForm
-		err := c.ShouldBindJSON(&userForm)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to create user. Error: ", err)
-			c.AbortWithError(http.StatusUnprocessableEntity, err)
-			return
-		}
-		err = userForm.Validate()
-		if err != nil {
-			log.Default().Println("Error validating user while trying to create user. Error: ", err)
-			errors.ReturnErrorResponse(err, c)
-			return
-		}
-
-		user, err := s.Create(userForm.ToUserEntity())
-		if err != nil {
-			log.Default().Println("Error creating user while trying to create user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusCreated, user)
-		log.Default().Println("User created successfully")
-	}
-
-}
-
-func Update(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		log.Default().Println("Updating user started")
-		var userForm auth.UserForm
-		id, err := strconv.Atoi(c.Param("id"))
-		if err != nil {
-			log.Default().Println("Error converting id while trying to update user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
-			return
-		}
-		_, err = s.GetByID(id)
-		if err != nil {
-			log.Default().Println("Error getting user by id while trying to update user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		err = c.ShouldBindJSON(&userForm)
-		if err != nil {
-			log.Default().Println("Error binding json while trying to update user. Error: ", err)
-			c.AbortWithStatus(http.StatusUnprocessableEntity)
-			return
-		}
-
-		if userForm.Phone != "" {
-			err = userForm.ValidatePhone()
-			if err != nil {
-				log.Default().Println("Error validating phone while trying to update user. Error: ", err)
-				errors.ReturnErrorResponse(err, c)
-				return
-			}
-		}
-
-		user, err := s.Update(id, userForm.ToUserEntity())
-		if err != nil {
-			log.Default().Println("Error updating user while trying to update user. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		c.JSON(http.StatusOK, user)
-		log.Default().Println("User updated successfully")
-	}
-}
-
-func RefreshToken(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		refreshToken, err := c.Cookie("refresh_token")
-		if err != nil {
-			log.Default().Println("Error getting refresh_token while trying to refresh token. Error: ", err)
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "refresh_token not found in cookie"})
-			return
-		}
-
-		tokens, err := s.RefreshToken(refreshToken)
-		if err != nil {
-			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
-			c.AbortWithError(http.StatusUnprocessableEntity, err)
-			return
-		}
-
-		c.SetCookie("refresh_token", tokens["refresh"], 3600, "/", "localhost", false, true)
-		c.SetCookie("access_token", tokens["access"], 3600, "/", "localhost", false, true)
-		c.JSON(http.StatusOK, gin.H{"message": "success"})
-		log.Default().Println("Token refreshed successfully")
-	}
-}
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+func Handlers(s auth.UserService) *gin.Engine {
+	r := gin.Default()
+	// The client IP used to throttle logins is only read from ProxyHeader
+	// when the request comes from one of the trusted proxies
+	r.RemoteIPHeaders = []string{s.Config.ProxyHeader}
+	if err := r.SetTrustedProxies(s.Config.TrustedProxies); err != nil {
+		log.Fatalf("Error setting trusted proxies: %s", err)
+	}
+	r.Handle("GET", "/.well-known/jwks.json", JWKS(s))
+	accountsGroup := r.Group("/accounts")
+	{
+		accountsGroup.Handle("POST", "/login", Login(s))
+		accountsGroup.Handle("POST", "/login/code", RequestLoginCode(s))
+		accountsGroup.Handle("POST", "/login/code/verify", LoginWithCode(s))
+		accountsGroup.Handle("DELETE", "/logout", Logout(s))
+		accountsGroup.Handle("DELETE", "/logout/all", middlewares.AuthMiddleware(s), LogoutAll(s))
+		accountsGroup.Handle("POST", "/signup", Signup(s))
+		accountsGroup.Handle("POST", "/refresh", RefreshToken(s))
+		accountsGroup.Handle("GET", "/me", middlewares.ScopedAuthMiddleware(s, auth.ScopeProfile), GetMe(s))
+		accountsGroup.Handle("PATCH", "/me", middlewares.AuthMiddleware(s), UpdateMe(s))
+		accountsGroup.Handle("DELETE", "/me", middlewares.AuthMiddleware(s), DeleteMe(s))
+		accountsGroup.Handle("POST", "/password", middlewares.AuthMiddleware(s), ChangePassword(s))
+		accountsGroup.Handle("POST", "/password/forgot", ForgotPassword(s))
+		accountsGroup.Handle("POST", "/password/reset", ResetPassword(s))
+		accountsGroup.Handle("POST", "/mfa/verify", VerifyMFA(s))
+		accountsGroup.Handle("POST", "/mfa/totp", middlewares.AuthMiddleware(s), EnrollTOTP(s))
+		accountsGroup.Handle("POST", "/mfa/totp/confirm", middlewares.AuthMiddleware(s), ConfirmTOTP(s))
+		accountsGroup.Handle("DELETE", "/mfa", middlewares.AuthMiddleware(s), DisableMFA(s))
+		accountsGroup.Handle("POST", "/phone/verification", SendPhoneVerification(s))
+		accountsGroup.Handle("POST", "/phone/verify", VerifyPhone(s))
+		accountsGroup.Handle("POST", "/email/verification", middlewares.AuthMiddleware(s), SendEmailVerification(s))
+		accountsGroup.Handle("POST", "/email/verify", VerifyEmail(s))
+		accountsGroup.Handle("POST", "/webauthn/register/begin", middlewares.AuthMiddleware(s), BeginWebAuthnRegistration(s))
+		accountsGroup.Handle("POST", "/webauthn/register/finish", middlewares.AuthMiddleware(s), FinishWebAuthnRegistration(s))
+		accountsGroup.Handle("POST", "/webauthn/login/begin", BeginWebAuthnLogin(s))
+		accountsGroup.Handle("POST", "/webauthn/login/finish", FinishWebAuthnLogin(s))
+		accountsGroup.Handle("POST", "/webauthn/mfa/begin", BeginWebAuthnMFA(s))
+		accountsGroup.Handle("POST", "/webauthn/mfa/finish", FinishWebAuthnMFA(s))
+		accountsGroup.Handle("GET", "/webauthn/credentials", middlewares.AuthMiddleware(s), GetWebAuthnCredentials(s))
+		accountsGroup.Handle("DELETE", "/webauthn/credentials/:id", middlewares.AuthMiddleware(s), DeleteWebAuthnCredential(s))
+		accountsGroup.Handle("GET", "/sessions", middlewares.AuthMiddleware(s), GetSessions(s))
+		accountsGroup.Handle("DELETE", "/sessions/:id", middlewares.AuthMiddleware(s), RevokeSession(s))
+	}
+	usersGroup := r.Group("/users").Use(middlewares.AuthMiddleware(s))
+	{
+		usersGroup.Handle("POST", "", middlewares.RequirePermission(s, auth.PermissionCreateUsers), Create(s))
+		usersGroup.Handle("GET", "", middlewares.RequirePermission(s, auth.PermissionReadUsers), GetAll(s))
+		usersGroup.Handle("GET", ":id", middlewares.RequireSelfOrPermission(s, auth.PermissionReadSelf, auth.PermissionReadUsers), GetByID(s))
+		usersGroup.Handle("DELETE", ":id", middlewares.RequirePermission(s, auth.PermissionDeleteUsers), Delete(s))
+		usersGroup.Handle("POST", ":id/unlock", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), UnlockLogin(s))
+		usersGroup.Handle("POST", ":id/activate", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), Activate(s))
+		usersGroup.Handle("POST", ":id/deactivate", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), Deactivate(s))
+		usersGroup.Handle("GET", ":id/sessions", middlewares.RequirePermission(s, auth.PermissionReadUsers), GetUserSessions(s))
+		usersGroup.Handle("DELETE", ":id/sessions/:session_id", middlewares.RequirePermission(s, auth.PermissionUpdateUsers), RevokeUserSession(s))
+		usersGroup.Handle("PATCH", ":id", middlewares.RequireSelfOrPermission(s, auth.PermissionUpdateSelf, auth.PermissionUpdateUsers), Update(s))
+	}
+	adminGroup := r.Group("/admin").Use(middlewares.AuthMiddleware(s), middlewares.RequireRole(s, auth.RoleAdmin))
+	{
+		adminGroup.Handle("POST", "/keys/rotate", middlewares.RequirePermission(s, auth.PermissionRotateKeys), RotateSigningKey(s))
+		adminGroup.Handle("POST", "/oauth/clients", middlewares.RequirePermission(s, auth.PermissionManageClients), RegisterOAuthClient(s))
+		adminGroup.Handle("GET", "/oauth/clients", middlewares.RequirePermission(s, auth.PermissionManageClients), GetOAuthClients(s))
+		adminGroup.Handle("DELETE", "/oauth/clients/:id", middlewares.RequirePermission(s, auth.PermissionManageClients), DeleteOAuthClient(s))
+	}
+	oauthGroup := r.Group("/oauth")
+	{
+		oauthGroup.Handle("POST", "/introspect", Introspect(s))
+		oauthGroup.Handle("GET", "/authorize", middlewares.AuthMiddleware(s), Authorize(s))
+		oauthGroup.Handle("POST", "/authorize", middlewares.AuthMiddleware(s), Consent(s))
+		oauthGroup.Handle("POST", "/token", Token(s))
+	}
+
+	return r
+
+}
+
+func Create(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Creating user started")
+		var user auth.User
+		err := c.ShouldBindJSON(&user)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to create user. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+
+		err = user.Validate()
+		if err != nil {
+			log.Default().Println("Error validating user while trying to create user. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err = s.Create(user)
+		if err != nil {
+			log.Default().Println("Error creating user while trying to create user. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+		c.JSON(http.StatusCreated, user)
+		log.Default().Println("User created successfully")
+	}
+
+}
+
+func Delete(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Deleting user started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to delete user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to delete user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err := s.Delete(id); err != nil {
+			log.Default().Println("Error deleting user while trying to delete user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("User deleted successfully")
+	}
+}
+
+func UnlockLogin(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Unlocking user login started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to unlock user login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to unlock user login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err := s.UnlockLogin(id); err != nil {
+			log.Default().Println("Error unlocking user login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("User login unlocked successfully")
+	}
+}
+
+func Activate(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Activating user started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to activate user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		if err := s.Activate(id); err != nil {
+			log.Default().Println("Error activating user. Error: ", err)
+			if err == auth.ErrUserNotFound {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("User activated successfully")
+	}
+}
+
+func Deactivate(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Deactivating user started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to deactivate user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		if err := s.Deactivate(id); err != nil {
+			log.Default().Println("Error deactivating user. Error: ", err)
+			if err == auth.ErrUserNotFound {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("User deactivated successfully")
+	}
+}
+
+func GetAll(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting all users started")
+		users, err := s.GetAll()
+		if err != nil {
+			log.Default().Println("Error getting all users. Error: ", err)
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, users)
+		log.Default().Println("Users fetched successfully")
+	}
+}
+
+func GetByID(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting user by id started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		user, err := s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusOK, user)
+		log.Default().Println("User fetched successfully")
+	}
+}
+
+func Login(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Login started")
+		var userLogin auth.UserLogin
+		err := c.ShouldBindJSON(&userLogin)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to login. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+		user, err := s.Login(userLogin.Username, userLogin.Password, c.ClientIP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error logging in. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error logging in. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrWrongCredentials.Error()})
+			return
+		}
+		mfaRequired, err := s.MFARequired(user)
+		if err != nil {
+			log.Default().Println("Error checking mfa while trying to login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if mfaRequired {
+			respondWithMFAToken(c, s, user)
+			log.Default().Println("Login waiting for mfa code")
+			return
+		}
+		tokens, err := s.GenerateJWT(user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Login successful")
+	}
+}
+
+func Logout(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Logout started")
+		accessToken := middlewares.AccessToken(c, s.Config)
+		refreshToken := requestRefreshToken(c, s)
+		if err := s.Logout(accessToken, refreshToken); err != nil {
+			log.Default().Println("Error revoking tokens while trying to logout. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		clearTokenCookies(c)
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Logout successful")
+	}
+}
+
+func LogoutAll(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Logout from all sessions started")
+		principal, _ := middlewares.Principal(c)
+		if err := s.LogoutAll(principal.Claims.ID); err != nil {
+			log.Default().Println("Error revoking tokens while trying to logout from all sessions. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		clearTokenCookies(c)
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Logout from all sessions successful")
+	}
+}
+
+func Signup(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Signup started")
+		var userForm auth.UserForm
+		err := c.ShouldBindJSON(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to create user. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+		err = userForm.Validate()
+		if err != nil {
+			log.Default().Println("Error validating user while trying to create user. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err := s.Signup(userForm.ToUserEntity())
+		if err != nil {
+			log.Default().Println("Error creating user while trying to create user. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+		if s.Config.EnumerationSafeSignup {
+			c.JSON(http.StatusAccepted, gin.H{"message": "signup received, you can now log in to your account"})
+		} else {
+			c.JSON(http.StatusCreated, user)
+		}
+		log.Default().Println("Signup finished")
+	}
+
+}
+
+func Update(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Updating user started")
+		var userForm auth.UserForm
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to update user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to update user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		err = c.ShouldBindJSON(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to update user. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+
+		if userForm.Phone != "" {
+			err = userForm.ValidatePhone()
+			if err != nil {
+				log.Default().Println("Error validating phone while trying to update user. Error: ", err)
+				errors.ReturnErrorResponse(err, c)
+				return
+			}
+		}
+		if userForm.Email != "" {
+			err = userForm.ValidateEmail()
+			if err != nil {
+				log.Default().Println("Error validating email while trying to update user. Error: ", err)
+				errors.ReturnErrorResponse(err, c)
+				return
+			}
+		}
+
+		// Users editing themselves go through UpdateSelf so the password can
+		// only be changed with the current one
+		var user auth.User
+		if principal, _ := middlewares.Principal(c); principal.Claims.ID == id {
+			user, err = s.UpdateSelf(id, userForm.ToUserEntity(), userForm.CurrentPassword)
+		} else {
+			user, err = s.Update(id, userForm.ToUserEntity())
+		}
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to update user. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error updating user while trying to update user. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+		log.Default().Println("User updated successfully")
+	}
+}
+
+func RefreshToken(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		refreshToken := requestRefreshToken(c, s)
+		if refreshToken == "" {
+			log.Default().Println("Error getting refresh_token while trying to refresh token.")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "refresh_token not found in request"})
+			return
+		}
+
+		tokens, err := s.RefreshToken(refreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err == auth.ErrRefreshTokenReused {
+			log.Default().Println("Refresh token reuse detected, token family revoked.")
+			clearTokenCookies(c)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive {
+			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
+			clearTokenCookies(c)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error refreshing token while trying to refresh token. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Token refreshed successfully")
+	}
+}
+
+func JWKS(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, s.JWKS())
+	}
+}
+
+func RotateSigningKey(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Signing key rotation started")
+		key, err := s.RotateSigningKey()
+		if err != nil {
+			log.Default().Println("Error rotating signing key. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"kid": key.ID})
+		log.Default().Println("Signing key rotated successfully")
+	}
+}
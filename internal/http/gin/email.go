@@ -0,0 +1,66 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+func SendEmailVerification(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Email verification started")
+		principal, _ := middlewares.Principal(c)
+		err := s.SendEmailVerification(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error sending email verification. Error: ", err)
+			c.AbortWithStatusJSON(emailErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "a verification link has been sent to your email address"})
+		log.Default().Println("Email verification finished")
+	}
+}
+
+func VerifyEmail(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Verifying email started")
+		var form auth.VerifyEmailForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify email. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify email. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.VerifyEmail(form.Token)
+		if err != nil {
+			log.Default().Println("Error verifying email. Error: ", err)
+			c.AbortWithStatusJSON(emailErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Email verified successfully")
+	}
+}
+
+func emailErrorStatus(err error) int {
+	switch err {
+	case auth.ErrEmailVerificationDisabled:
+		return http.StatusNotImplemented
+	case auth.ErrEmailAlreadyVerified:
+		return http.StatusConflict
+	case auth.ErrNoEmail, auth.ErrInvalidToken:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,124 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+func GetMe(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting current user started")
+		principal, _ := middlewares.Principal(c)
+		user, err := s.GetByID(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error getting current user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusOK, user.Profile())
+		log.Default().Println("Current user fetched successfully")
+	}
+}
+
+func UpdateMe(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Updating current user started")
+		principal, _ := middlewares.Principal(c)
+		var userForm auth.UserForm
+		err := c.ShouldBindJSON(&userForm)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to update current user. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+
+		if userForm.Phone != "" {
+			err = userForm.ValidatePhone()
+			if err != nil {
+				log.Default().Println("Error validating phone while trying to update current user. Error: ", err)
+				errors.ReturnErrorResponse(err, c)
+				return
+			}
+		}
+		if userForm.Email != "" {
+			err = userForm.ValidateEmail()
+			if err != nil {
+				log.Default().Println("Error validating email while trying to update current user. Error: ", err)
+				errors.ReturnErrorResponse(err, c)
+				return
+			}
+		}
+
+		user, err := s.UpdateSelf(principal.Claims.ID, userForm.ToUserEntity(), userForm.CurrentPassword)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to update current user. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error updating current user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, user.Profile())
+		log.Default().Println("Current user updated successfully")
+	}
+}
+
+func DeleteMe(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Deleting current user started")
+		principal, _ := middlewares.Principal(c)
+		if err := s.DeleteSelf(principal.Claims.ID); err != nil {
+			log.Default().Println("Error deleting current user. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		clearTokenCookies(c)
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Current user deleted successfully")
+	}
+}
+
+func ChangePassword(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Changing password started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.PasswordChangeForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to change password. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to change password. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.ChangePassword(principal.Claims, form.CurrentPassword, form.NewPassword)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password check throttled while trying to change password. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error changing password. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Password changed successfully")
+	}
+}
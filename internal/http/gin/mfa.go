@@ -0,0 +1,161 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+// respondWithMFAToken answers a password or code login of a user with
+// two-factor authentication enabled. Tokens are issued by VerifyMFA.
+func respondWithMFAToken(c *gin.Context, s auth.UserService, user auth.User) {
+	token, err := s.GenerateMFAToken(user)
+	if err != nil {
+		log.Default().Println("Error generating mfa token. Error: ", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "mfa required", "mfa_token": token})
+}
+
+func VerifyMFA(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Verifying mfa code started")
+		var form auth.MFAVerifyForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify mfa code. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify mfa code. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err := s.VerifyMFA(form.MFAToken, form.Code)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Mfa verification throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error verifying mfa code. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error verifying mfa code. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrInvalidMFACode.Error()})
+			return
+		}
+		tokens, err := s.GenerateJWT(user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Mfa code verified successfully")
+	}
+}
+
+func EnrollTOTP(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Enrolling totp started")
+		principal, _ := middlewares.Principal(c)
+		enrollment, err := s.EnrollTOTP(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error enrolling totp. Error: ", err)
+			c.AbortWithStatusJSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, enrollment)
+		log.Default().Println("Totp enrolled successfully")
+	}
+}
+
+func ConfirmTOTP(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Confirming totp started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.MFACodeForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to confirm totp. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to confirm totp. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		codes, err := s.ConfirmTOTP(principal.Claims.ID, form.Code)
+		if err != nil {
+			log.Default().Println("Error confirming totp. Error: ", err)
+			c.AbortWithStatusJSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success", "recovery_codes": codes})
+		log.Default().Println("Totp confirmed successfully")
+	}
+}
+
+func DisableMFA(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Disabling mfa started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.MFADisableForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to disable mfa. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to disable mfa. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.DisableMFA(principal.Claims, form.Password, form.Code)
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Disabling mfa throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error disabling mfa. Error: ", err)
+			c.AbortWithStatusJSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Mfa disabled successfully")
+	}
+}
+
+func mfaErrorStatus(err error) int {
+	switch err {
+	case auth.ErrMFADisabled:
+		return http.StatusNotImplemented
+	case auth.ErrMFAAlreadyEnabled:
+		return http.StatusConflict
+	case auth.ErrMFANotEnrolled, auth.ErrInvalidMFACode, auth.ErrWrongCredentials:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,30 +1,85 @@
-package middlewares
-
-import (
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"github.com/mohaali482/goAuth/auth"
-)
-
-func AuthMiddleware(s auth.UserService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("access_token")
-		if err != nil || tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
-			c.Abort()
-			return
-		}
-
-		_, err = s.ValidateJWT(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-
-	}
-
-}
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/config"
+)
+
+// AuthMiddleware authenticates first party callers. Access tokens issued to
+// OAuth clients are rejected.
+func AuthMiddleware(s auth.UserService) gin.HandlerFunc {
+	return ScopedAuthMiddleware(s, "")
+}
+
+// ScopedAuthMiddleware authenticates first party callers and OAuth clients
+// that were granted the scope
+func ScopedAuthMiddleware(s auth.UserService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := AccessToken(c, s.Config)
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
+			c.Abort()
+			return
+		}
+
+		principal, err := s.Principal(tokenString)
+		if err == auth.ErrUserInactive {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+			c.Abort()
+			return
+		}
+		if !principal.Claims.AllowsScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": auth.ErrInsufficientScope.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set(auth.PrincipalKey, principal)
+		c.Request = c.Request.WithContext(auth.ContextWithPrincipal(c.Request.Context(), principal))
+		c.Next()
+
+	}
+
+}
+
+// Principal returns the caller authenticated by AuthMiddleware
+func Principal(c *gin.Context) (auth.Principal, bool) {
+	return auth.PrincipalFromContext(c.Request.Context())
+}
+
+// AccessToken returns the access token of the request looking it up in the
+// places configured in Config.TokenLookup, in order
+func AccessToken(c *gin.Context, cfg *config.Config) string {
+	return lookupToken(c, cfg, "access_token", true)
+}
+
+// RefreshToken returns the refresh token cookie of the request when cookies
+// are part of Config.TokenLookup. The Authorization header carries the access
+// token and is never read for the refresh token.
+func RefreshToken(c *gin.Context, cfg *config.Config) string {
+	return lookupToken(c, cfg, "refresh_token", false)
+}
+
+func lookupToken(c *gin.Context, cfg *config.Config, cookie string, header bool) string {
+	for _, source := range cfg.TokenLookup {
+		switch source {
+		case config.TokenLookupHeader:
+			if token := auth.BearerToken(c.GetHeader("Authorization")); header && token != "" {
+				return token
+			}
+		case config.TokenLookupCookie:
+			if token, err := c.Cookie(cookie); err == nil && token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
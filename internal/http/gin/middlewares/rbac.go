@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+)
+
+// RequireRole only lets callers with one of the roles through. It must run
+// after AuthMiddleware.
+func RequireRole(s auth.UserService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := Principal(c)
+		if !ok || !s.HasRole(principal.Claims, roles...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrForbidden.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission only lets callers whose role grants one of the
+// permissions through. It must run after AuthMiddleware.
+func RequirePermission(s auth.UserService, permissions ...auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := Principal(c)
+		if !ok || !hasAnyPermission(s, principal, permissions) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrForbidden.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets callers with selfPermission act on the user
+// identified by the :id route parameter when it is themselves, and callers
+// with permission act on any user. It must run after AuthMiddleware.
+func RequireSelfOrPermission(s auth.UserService, selfPermission auth.Permission, permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := Principal(c)
+		if ok && s.HasPermission(principal.Claims, permission) {
+			c.Next()
+			return
+		}
+		if ok && c.Param("id") == strconv.Itoa(principal.Claims.ID) && s.HasPermission(principal.Claims, selfPermission) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrForbidden.Error()})
+	}
+}
+
+func hasAnyPermission(s auth.UserService, principal auth.Principal, permissions []auth.Permission) bool {
+	for _, permission := range permissions {
+		if s.HasPermission(principal.Claims, permission) {
+			return true
+		}
+	}
+	return false
+}
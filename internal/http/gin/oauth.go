@@ -0,0 +1,236 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+// Introspect answers token introspection requests of protected resources as
+// defined by RFC 7662
+func Introspect(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Token introspection started")
+		clientID, clientSecret, _ := auth.BasicCredentials(c.GetHeader("Authorization"))
+		err := s.AuthenticateIntrospectionClient(clientID, clientSecret)
+		if err == auth.ErrIntrospectionDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error authenticating client while trying to introspect token. Error: ", err)
+			c.Header("WWW-Authenticate", `Basic realm="introspection"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			return
+		}
+		token := c.PostForm("token")
+		if token == "" {
+			log.Default().Println("Error getting token while trying to introspect token.")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+			return
+		}
+
+		response := s.Introspect(token, c.PostForm("token_type_hint"))
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, response)
+		log.Default().Println("Token introspection finished")
+	}
+}
+
+// Authorize validates an authorization request of a client and returns what
+// the consent step shows the logged in user
+func Authorize(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Authorization request started")
+		request := authorizationRequest(c)
+		client, err := s.ValidateAuthorizationRequest(request)
+		if err != nil {
+			log.Default().Println("Error validating authorization request. Error: ", err)
+			respondWithAuthorizationError(c, s, request, err)
+			return
+		}
+		scope, _ := auth.ParseScope(request.Scope)
+		c.JSON(http.StatusOK, gin.H{
+			"client_id":    client.ID,
+			"client_name":  client.Name,
+			"redirect_uri": request.RedirectURI,
+			"scope":        scope,
+		})
+		log.Default().Println("Authorization request validated successfully")
+	}
+}
+
+// Consent records the answer of the logged in user to an authorization
+// request and returns where to redirect the user
+func Consent(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Authorization consent started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.AuthorizationConsentForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to record authorization consent. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+
+		var redirectTo string
+		if form.Approve {
+			redirectTo, err = s.Authorize(principal.Claims.ID, form.AuthorizationRequest)
+		} else {
+			redirectTo, err = s.DenyAuthorization(form.AuthorizationRequest)
+		}
+		if err != nil {
+			log.Default().Println("Error recording authorization consent. Error: ", err)
+			respondWithAuthorizationError(c, s, form.AuthorizationRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+		log.Default().Println("Authorization consent recorded successfully")
+	}
+}
+
+// Token is the OAuth 2.0 token endpoint. Clients authenticate with http
+// basic auth or the client_id and client_secret parameters.
+func Token(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Token request started")
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+		clientID, clientSecret, basic := auth.BasicCredentials(c.GetHeader("Authorization"))
+		if !basic {
+			clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+		}
+		client, err := s.AuthenticateOAuthClient(clientID, clientSecret)
+		if err != nil {
+			log.Default().Println("Error authenticating client while trying to grant token. Error: ", err)
+			if basic {
+				c.Header("WWW-Authenticate", `Basic realm="token"`)
+			}
+			c.AbortWithStatusJSON(oauthErrorStatus(err), gin.H{"error": auth.OAuthErrorCode(err), "error_description": err.Error()})
+			return
+		}
+
+		response, err := s.GrantToken(client, auth.TokenRequest{
+			GrantType:    c.PostForm("grant_type"),
+			Code:         c.PostForm("code"),
+			RedirectURI:  c.PostForm("redirect_uri"),
+			CodeVerifier: c.PostForm("code_verifier"),
+			RefreshToken: c.PostForm("refresh_token"),
+		}, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error granting token. Error: ", err)
+			c.AbortWithStatusJSON(oauthErrorStatus(err), gin.H{"error": auth.OAuthErrorCode(err), "error_description": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		log.Default().Println("Token granted successfully")
+	}
+}
+
+func RegisterOAuthClient(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Registering oauth client started")
+		var form auth.OAuthClientForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to register oauth client. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to register oauth client. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		client, err := s.RegisterOAuthClient(form)
+		if err != nil {
+			log.Default().Println("Error registering oauth client. Error: ", err)
+			c.AbortWithStatusJSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, client)
+		log.Default().Println("Oauth client registered successfully")
+	}
+}
+
+func GetOAuthClients(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting oauth clients started")
+		clients, err := s.GetOAuthClients()
+		if err != nil {
+			log.Default().Println("Error getting oauth clients. Error: ", err)
+			c.AbortWithStatusJSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, clients)
+		log.Default().Println("Oauth clients fetched successfully")
+	}
+}
+
+func DeleteOAuthClient(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Deleting oauth client started")
+		err := s.DeleteOAuthClient(c.Param("id"))
+		if err == auth.ErrInvalidClient {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "client not found"})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error deleting oauth client. Error: ", err)
+			c.AbortWithStatusJSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Oauth client deleted successfully")
+	}
+}
+
+func authorizationRequest(c *gin.Context) auth.AuthorizationRequest {
+	return auth.AuthorizationRequest{
+		ResponseType:        c.Query("response_type"),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+	}
+}
+
+// respondWithAuthorizationError reports an invalid authorization request.
+// Unless the client or the redirect uri is invalid, the response also tells
+// where to redirect the user to report the error to the client.
+func respondWithAuthorizationError(c *gin.Context, s auth.UserService, r auth.AuthorizationRequest, err error) {
+	response := gin.H{"error": auth.OAuthErrorCode(err), "error_description": err.Error()}
+	switch err {
+	case auth.ErrOAuthDisabled, auth.ErrInvalidClient, auth.ErrInvalidRedirectURI:
+	default:
+		if oauthErrorStatus(err) == http.StatusBadRequest {
+			response["redirect_to"] = s.AuthorizationErrorRedirect(r, err)
+		}
+	}
+	status := oauthErrorStatus(err)
+	if err == auth.ErrInvalidClient {
+		status = http.StatusBadRequest
+	}
+	c.AbortWithStatusJSON(status, response)
+}
+
+func oauthErrorStatus(err error) int {
+	switch auth.OAuthErrorCode(err) {
+	case "invalid_client":
+		return http.StatusUnauthorized
+	case "temporarily_unavailable":
+		return http.StatusNotImplemented
+	case "server_error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,75 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+)
+
+func ForgotPassword(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Forgot password started")
+		var form auth.ForgotPasswordForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to start password reset. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to start password reset. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.ForgotPassword(form.Identifier, c.ClientIP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Password reset throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrPasswordResetDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error starting password reset. Error: ", err)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists, password reset instructions have been sent"})
+		log.Default().Println("Forgot password finished")
+	}
+}
+
+func ResetPassword(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Password reset started")
+		var form auth.ResetPasswordForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to reset password. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to reset password. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.ResetPassword(form.Token, form.Password)
+		if err != nil {
+			log.Default().Println("Error resetting password. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Password reset successfully")
+	}
+}
@@ -0,0 +1,108 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+)
+
+func RequestLoginCode(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Login code request started")
+		var form auth.LoginCodeRequestForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to request login code. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to request login code. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.RequestLoginCode(form.Identifier, c.ClientIP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login code request throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrPasswordlessDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error requesting login code. Error: ", err)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists, a login code has been sent"})
+		log.Default().Println("Login code request finished")
+	}
+}
+
+func LoginWithCode(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Login with code started")
+		var form auth.LoginCodeForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to login with code. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to login with code. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err := s.LoginWithCode(form.Identifier, form.Code, c.ClientIP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Login with code throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrPasswordlessDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error logging in with code. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error logging in with code. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrInvalidLoginCode.Error()})
+			return
+		}
+		mfaRequired, err := s.MFARequired(user)
+		if err != nil {
+			log.Default().Println("Error checking mfa while trying to login with code. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if mfaRequired {
+			respondWithMFAToken(c, s, user)
+			log.Default().Println("Login with code waiting for mfa code")
+			return
+		}
+		tokens, err := s.GenerateJWT(user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Login with code successful")
+	}
+}
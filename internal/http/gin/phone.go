@@ -0,0 +1,77 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+)
+
+func SendPhoneVerification(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Phone verification started")
+		var form auth.PhoneVerificationRequestForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to send phone verification. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to send phone verification. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.SendPhoneVerification(form.Phone)
+		if err == auth.ErrPhoneVerificationDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error sending phone verification. Error: ", err)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "if the phone number needs verification, a code has been sent"})
+		log.Default().Println("Phone verification finished")
+	}
+}
+
+func VerifyPhone(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Verifying phone started")
+		var form auth.PhoneVerificationForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to verify phone. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to verify phone. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		err = s.VerifyPhone(form.Phone, form.Code)
+		if err == auth.ErrPhoneVerificationDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrInvalidVerificationCode {
+			log.Default().Println("Error verifying phone. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error verifying phone. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Phone verified successfully")
+	}
+}
@@ -0,0 +1,100 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+func GetSessions(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting sessions started")
+		principal, _ := middlewares.Principal(c)
+		sessions, err := s.GetSessions(principal.Claims.ID, principal.Claims.Family)
+		if err != nil {
+			log.Default().Println("Error getting sessions. Error: ", err)
+			c.AbortWithStatusJSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, sessions)
+		log.Default().Println("Sessions fetched successfully")
+	}
+}
+
+func RevokeSession(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Revoking session started")
+		principal, _ := middlewares.Principal(c)
+		err := s.RevokeSession(principal.Claims.ID, c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error revoking session. Error: ", err)
+			c.AbortWithStatusJSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if c.Param("id") == principal.Claims.Family {
+			clearTokenCookies(c)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Session revoked successfully")
+	}
+}
+
+func GetUserSessions(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting user sessions started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to get user sessions. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		_, err = s.GetByID(id)
+		if err != nil {
+			log.Default().Println("Error getting user by id while trying to get user sessions. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		sessions, err := s.GetSessions(id, "")
+		if err != nil {
+			log.Default().Println("Error getting user sessions. Error: ", err)
+			c.AbortWithStatusJSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, sessions)
+		log.Default().Println("User sessions fetched successfully")
+	}
+}
+
+func RevokeUserSession(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Revoking user session started")
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error converting id while trying to revoke user session. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id is not a valid id"})
+			return
+		}
+		err = s.RevokeSession(id, c.Param("session_id"))
+		if err != nil {
+			log.Default().Println("Error revoking user session. Error: ", err)
+			c.AbortWithStatusJSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("User session revoked successfully")
+	}
+}
+
+func sessionErrorStatus(err error) int {
+	switch err {
+	case auth.ErrSessionsDisabled:
+		return http.StatusNotImplemented
+	case auth.ErrSessionNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,45 @@
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+type refreshTokenForm struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// respondWithTokens delivers an issued token pair as cookies, in the
+// response body or both depending on Config.TokenDelivery
+func respondWithTokens(c *gin.Context, s auth.UserService, tokens map[string]string) {
+	if s.Config.DeliverTokensInCookies() {
+		c.SetCookie("refresh_token", tokens["refresh"], 3600, "/", "localhost", false, true)
+		c.SetCookie("access_token", tokens["access"], 3600, "/", "localhost", false, true)
+	}
+	if s.Config.DeliverTokensInBody() {
+		c.JSON(http.StatusOK, gin.H{"message": "success", "access": tokens["access"], "refresh": tokens["refresh"]})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+func clearTokenCookies(c *gin.Context) {
+	c.SetCookie("refresh_token", "", 0, "/", "localhost", false, true)
+	c.SetCookie("access_token", "", 0, "/", "localhost", false, true)
+}
+
+// requestRefreshToken looks up the refresh token in its cookie and falls back
+// to the refresh_token field of a JSON body
+func requestRefreshToken(c *gin.Context, s auth.UserService) string {
+	if token := middlewares.RefreshToken(c, s.Config); token != "" {
+		return token
+	}
+	var form refreshTokenForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		return ""
+	}
+	return form.RefreshToken
+}
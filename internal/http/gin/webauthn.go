@@ -0,0 +1,238 @@
+package gin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohaali482/goAuth/auth"
+	"github.com/mohaali482/goAuth/internal/http/gin/errors"
+	"github.com/mohaali482/goAuth/internal/http/gin/middlewares"
+)
+
+func BeginWebAuthnRegistration(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Beginning webauthn registration started")
+		principal, _ := middlewares.Principal(c)
+		options, err := s.BeginWebAuthnRegistration(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error beginning webauthn registration. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"publicKey": options})
+		log.Default().Println("Webauthn registration begun successfully")
+	}
+}
+
+func FinishWebAuthnRegistration(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Finishing webauthn registration started")
+		principal, _ := middlewares.Principal(c)
+		var form auth.WebAuthnRegistrationForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn registration. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn registration. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		credential, err := s.FinishWebAuthnRegistration(principal.Claims.ID, form.Name, form.SecondFactor, form.Credential)
+		if err != nil {
+			log.Default().Println("Error finishing webauthn registration. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, credential)
+		log.Default().Println("Webauthn registration finished successfully")
+	}
+}
+
+func BeginWebAuthnLogin(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Beginning webauthn login started")
+		options, err := s.BeginWebAuthnLogin(c.ClientIP())
+		if throttled, ok := err.(*auth.LoginThrottledError); ok {
+			log.Default().Println("Webauthn login throttled. Error: ", err)
+			c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error beginning webauthn login. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"publicKey": options})
+		log.Default().Println("Webauthn login begun successfully")
+	}
+}
+
+func FinishWebAuthnLogin(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Finishing webauthn login started")
+		var form auth.WebAuthnLoginForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn login. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn login. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err := s.FinishWebAuthnLogin(form.Credential, c.ClientIP())
+		if err == auth.ErrWebAuthnDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error finishing webauthn login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error finishing webauthn login. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrInvalidWebAuthnResponse.Error()})
+			return
+		}
+		tokens, err := s.GenerateJWT(user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Webauthn login finished successfully")
+	}
+}
+
+func BeginWebAuthnMFA(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Beginning webauthn mfa started")
+		var form auth.WebAuthnMFABeginForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to begin webauthn mfa. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to begin webauthn mfa. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		options, err := s.BeginWebAuthnMFA(form.MFAToken)
+		if err != nil {
+			log.Default().Println("Error beginning webauthn mfa. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"publicKey": options})
+		log.Default().Println("Webauthn mfa begun successfully")
+	}
+}
+
+func FinishWebAuthnMFA(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Finishing webauthn mfa started")
+		var form auth.WebAuthnMFAForm
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			log.Default().Println("Error binding json while trying to finish webauthn mfa. Error: ", err)
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		err = form.Validate()
+		if err != nil {
+			log.Default().Println("Error validating form while trying to finish webauthn mfa. Error: ", err)
+			errors.ReturnErrorResponse(err, c)
+			return
+		}
+
+		user, err := s.FinishWebAuthnMFA(form.MFAToken, form.Credential)
+		if err == auth.ErrWebAuthnDisabled {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		if err == auth.ErrUserInactive || err == auth.ErrPhoneNotVerified {
+			log.Default().Println("Error finishing webauthn mfa. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Default().Println("Error finishing webauthn mfa. Error: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrInvalidWebAuthnResponse.Error()})
+			return
+		}
+		tokens, err := s.GenerateJWT(user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Default().Println("Error generating tokens. Error: ", err)
+			c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respondWithTokens(c, s, tokens)
+		log.Default().Println("Webauthn mfa finished successfully")
+	}
+}
+
+func GetWebAuthnCredentials(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Getting webauthn credentials started")
+		principal, _ := middlewares.Principal(c)
+		credentials, err := s.GetWebAuthnCredentials(principal.Claims.ID)
+		if err != nil {
+			log.Default().Println("Error getting webauthn credentials. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, credentials)
+		log.Default().Println("Webauthn credentials fetched successfully")
+	}
+}
+
+func DeleteWebAuthnCredential(s auth.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Default().Println("Deleting webauthn credential started")
+		principal, _ := middlewares.Principal(c)
+		err := s.DeleteWebAuthnCredential(principal.Claims.ID, c.Param("id"))
+		if err != nil {
+			log.Default().Println("Error deleting webauthn credential. Error: ", err)
+			c.AbortWithStatusJSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+		log.Default().Println("Webauthn credential deleted successfully")
+	}
+}
+
+func webAuthnErrorStatus(err error) int {
+	switch err {
+	case auth.ErrWebAuthnDisabled:
+		return http.StatusNotImplemented
+	case auth.ErrWebAuthnCredentialExists:
+		return http.StatusConflict
+	case auth.ErrWebAuthnCredentialNotFound:
+		return http.StatusNotFound
+	case auth.ErrInvalidWebAuthnResponse, auth.ErrInvalidAttestation, auth.ErrUnsupportedKey, auth.ErrMFANotEnrolled:
+		return http.StatusBadRequest
+	case auth.ErrInvalidToken:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}